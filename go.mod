@@ -1,3 +1,5 @@
 module signup-checker
 
 go 1.21
+
+require golang.org/x/text v0.14.0
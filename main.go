@@ -1,559 +1,2861 @@
 package main
 
 import (
+	"archive/zip"
 	"bufio"
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	_ "embed"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"flag"
 	"fmt"
+	"html"
+	"io"
+	"io/fs"
 	"log"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+
+	"signup-checker/signup"
 )
 
-// Player represents a guild member
-type Player struct {
-	Username string
-	Status   string
-	Roles    string
+// osFS opens input files through the operating system, relative to the working directory
+// or by absolute path, so the CLI can keep accepting any path
+type osFS struct{}
+
+// Open opens the named file for reading
+func (osFS) Open(name string) (fs.File, error) {
+	return os.Open(name)
 }
 
-// AlternativeNames holds mappings from guild names to alternative names
-type AlternativeNames struct {
-	GuildToAlternatives map[string][]string // guild name -> list of alternative names
-	AlternativeToGuild  map[string]string   // alternative name -> guild name
+// Stat returns the file info for the named file
+func (osFS) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(name)
 }
 
-// MatchResult represents the result of a name matching operation
-type MatchResult struct {
-	Found           bool
-	GuildName       string
-	AlternativeName string
-	MatchType       string // "direct", "alternative", "ignored"
+// ReadDir lists the named directory, for expanding sheet file globs
+func (osFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return os.ReadDir(name)
 }
 
-// waitForUserInput waits for the user to press Enter before continuing
-func waitForUserInput() {
-	fmt.Print("\nPress Enter to exit...")
-	bufio.NewReader(os.Stdin).ReadBytes('\n')
+// contextFS opens the files of another FS so that reading them fails once ctx is cancelled,
+// which stops parsing a huge file at the next read after Ctrl-C
+type contextFS struct {
+	fsys fs.FS
+	ctx  context.Context
 }
 
-// parseGuildFile reads and parses the guild.txt file
-func parseGuildFile(filename string) ([]Player, error) {
-	file, err := os.Open(filename)
+// Open opens the named file for reading until the context is cancelled
+func (c contextFS) Open(name string) (fs.File, error) {
+	file, err := c.fsys.Open(name)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open guild file: %w", err)
+		return nil, err
 	}
-	defer file.Close()
-
-	var players []Player
-	scanner := bufio.NewScanner(file)
-	lineNum := 0
+	return contextFile{File: file, ctx: c.ctx}, nil
+}
 
-	for scanner.Scan() {
-		lineNum++
-		line := strings.TrimSpace(scanner.Text())
+// Stat returns the file info for the named file
+func (c contextFS) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(c.fsys, name)
+}
 
-		// Skip empty lines and header
-		if line == "" || lineNum == 1 {
-			continue
-		}
+// ReadDir lists the named directory unless the context is cancelled
+func (c contextFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if err := c.ctx.Err(); err != nil {
+		return nil, err
+	}
+	return fs.ReadDir(c.fsys, name)
+}
 
-		player, err := parseGuildLine(line)
-		if err != nil {
-			log.Printf("Warning: skipping malformed line %d: %v", lineNum, err)
-			continue
-		}
+// contextFile is a file whose reads return the context's error once it is cancelled
+type contextFile struct {
+	fs.File
+	ctx context.Context
+}
 
-		players = append(players, player)
+// Read reads from the file unless the context is cancelled
+func (f contextFile) Read(p []byte) (int, error) {
+	if err := f.ctx.Err(); err != nil {
+		return 0, err
 	}
+	return f.File.Read(p)
+}
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading guild file: %w", err)
+// exitIfInterrupted ends the run when err comes from Ctrl-C or SIGTERM cancelling it, without
+// the message of a failed run; output files are written atomically, so none is left half-written
+func exitIfInterrupted(err error) {
+	if errors.Is(err, context.Canceled) {
+		fmt.Fprintln(os.Stderr, "Interrupted")
+		os.Exit(130)
 	}
+}
 
-	return players, nil
+// Clock provides the current time, so time-dependent checks can be tested
+type Clock interface {
+	Now() time.Time
 }
 
-// parseGuildLine parses a single line from guild.txt
-func parseGuildLine(line string) (Player, error) {
-	// Split by tabs
-	parts := strings.Split(line, "\t")
-	if len(parts) < 3 {
-		return Player{}, fmt.Errorf("expected 3 tab-separated fields, got %d", len(parts))
-	}
+// systemClock is the Clock backed by the system time
+type systemClock struct{}
 
-	// Extract quoted fields
-	username, err := extractQuotedField(parts[0])
-	if err != nil {
-		return Player{}, fmt.Errorf("invalid username field: %w", err)
-	}
+// Now returns the current system time
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
 
-	status, err := extractQuotedField(parts[1])
-	if err != nil {
-		return Player{}, fmt.Errorf("invalid status field: %w", err)
-	}
+// progress receives the "Loading..."/"Reading..." status messages
+var progress io.Writer = os.Stdout
 
-	roles, err := extractQuotedField(parts[2])
-	if err != nil {
-		return Player{}, fmt.Errorf("invalid roles field: %w", err)
+// RunInfo identifies a single run, so a stored report can be tied back to the execution that made it
+type RunInfo struct {
+	ID        string `json:"runId"`
+	Timestamp string `json:"timestamp"` // ISO-8601 in UTC
+}
+
+// newRunID returns a random version 4 UUID
+func newRunID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("failed to generate run ID: %w", err)
 	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
 
-	return Player{
-		Username: username,
-		Status:   status,
-		Roles:    roles,
-	}, nil
+// displayName returns the name as shown in the text report, title-cased when titleCase is set.
+// Only the display changes; matching and JSON output always use the raw name.
+func displayName(name string, titleCase bool) string {
+	if !titleCase {
+		return name
+	}
+	return cases.Title(language.Und).String(name)
 }
 
-// parseAlternativeNamesFile reads and parses the sheet-names.txt file
-func parseAlternativeNamesFile(filename string) (*AlternativeNames, error) {
-	altNames := &AlternativeNames{
-		GuildToAlternatives: make(map[string][]string),
-		AlternativeToGuild:  make(map[string]string),
+// mentionName returns a Discord mention like <@123> for a player with a known Discord ID,
+// and the display name otherwise
+func mentionName(name string, opts ReportOptions) string {
+	if id, exists := opts.Mentions[strings.ToLower(name)]; exists {
+		return "<@" + id + ">"
 	}
+	return displayName(name, opts.TitleCase)
+}
 
-	file, err := os.Open(filename)
+// applyConfigFile sets flags from a config file of "flag-name: value" lines, a small subset of
+// YAML: # comments, bare or quoted values, and "- value" items under a key for repeatable flags
+// like out. Flags given on the command line win over the file.
+func applyConfigFile(path string) error {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		// File doesn't exist, return empty mappings
-		return altNames, nil
+		return fmt.Errorf("failed to read config: %w", err)
 	}
-	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
+	explicit := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	set := func(lineNum int, key string, raw string) error {
+		value, err := configValue(raw)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", path, lineNum, err)
+		}
+		if explicit[key] {
+			return nil
+		}
+		if err := flag.Set(key, value); err != nil {
+			return fmt.Errorf("%s:%d: invalid value %q for %s: %w", path, lineNum, value, key, err)
+		}
+		return nil
+	}
 
-		// Skip empty lines and comments
+	listKey := ""
+	for i, line := range strings.Split(string(data), "\n") {
+		lineNum := i + 1
+		line = strings.TrimSpace(line)
 		if line == "" || strings.HasPrefix(line, "#") {
 			continue
 		}
 
-		// Parse line: GuildName:AlternativeName1,AlternativeName2
-		parts := strings.SplitN(line, ":", 2)
-		if len(parts) != 2 {
-			log.Printf("Warning: skipping malformed alternative name line: %s", line)
+		// List items belong to the last key that had no value of its own
+		if item, isItem := strings.CutPrefix(line, "- "); isItem {
+			if listKey == "" {
+				return fmt.Errorf("%s:%d: list item without a key", path, lineNum)
+			}
+			if err := set(lineNum, listKey, item); err != nil {
+				return err
+			}
 			continue
 		}
 
-		guildName := strings.TrimSpace(parts[0])
-		alternativesStr := strings.TrimSpace(parts[1])
-
-		if guildName == "" || alternativesStr == "" {
+		key, raw, found := strings.Cut(line, ":")
+		if !found {
+			return fmt.Errorf("%s:%d: expected \"flag-name: value\", got %q", path, lineNum, line)
+		}
+		key = strings.TrimSpace(key)
+		if flag.Lookup(key) == nil || key == "config" {
+			return fmt.Errorf("%s:%d: unknown setting %q", path, lineNum, key)
+		}
+		raw = strings.TrimSpace(raw)
+		listKey = ""
+		if raw == "" || strings.HasPrefix(raw, "#") {
+			listKey = key
 			continue
 		}
+		if err := set(lineNum, key, raw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-		// Parse alternative names
-		alternatives := strings.Split(alternativesStr, ",")
-		for _, alt := range alternatives {
-			alt = strings.TrimSpace(alt)
-			if alt != "" {
-				// Store both directions of mapping (case-insensitive)
-				altNames.GuildToAlternatives[guildName] = append(altNames.GuildToAlternatives[guildName], alt)
-				altNames.AlternativeToGuild[strings.ToLower(alt)] = guildName
+// configValue returns a config value without its quotes and any trailing comment; double-quoted
+// values take Go escapes like \t, single-quoted ones are literal with a doubled quote for one
+func configValue(raw string) (string, error) {
+	var value, rest string
+	switch {
+	case strings.HasPrefix(raw, `"`):
+		quoted, err := strconv.QuotedPrefix(raw)
+		if err != nil {
+			return "", fmt.Errorf("unterminated or invalid quoted value %s", raw)
+		}
+		value, _ = strconv.Unquote(quoted)
+		rest = raw[len(quoted):]
+	case strings.HasPrefix(raw, "'"):
+		end := 1
+		for {
+			next := strings.IndexByte(raw[end:], '\'')
+			if next < 0 {
+				return "", fmt.Errorf("unterminated quoted value %s", raw)
 			}
+			end += next + 1
+			if !strings.HasPrefix(raw[end:], "'") {
+				break
+			}
+			end++
 		}
+		value = strings.ReplaceAll(raw[1:end-1], "''", "'")
+		rest = raw[end:]
+	default:
+		value, _, _ = strings.Cut(raw, " #")
+		return strings.TrimSpace(value), nil
+	}
+	if rest = strings.TrimSpace(rest); rest != "" && !strings.HasPrefix(rest, "#") {
+		return "", fmt.Errorf("unexpected %q after quoted value", rest)
 	}
+	return value, nil
+}
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading alternative names file: %w", err)
+// printVerdict writes a one-line outcome to stderr, so it stays visible when stdout is piped elsewhere
+func printVerdict(missing int, extra int) {
+	fmt.Fprintf(os.Stderr, "DONE: %d need attention (%d missing, %d extra)\n", missing+extra, missing, extra)
+}
+
+// exitWithVerdict ends a run that stops before the full report, printing the verdict first unless quiet
+func exitWithVerdict(missing int, extra int, exitCode int, quiet bool) {
+	if !quiet {
+		printVerdict(missing, extra)
+	}
+	os.Exit(exitCode)
+}
+
+// waitForUserInput waits for the user to press Enter before continuing
+func waitForUserInput() {
+	fmt.Print("\nPress Enter to exit...")
+	bufio.NewReader(os.Stdin).ReadBytes('\n')
+}
+
+// writeFileAtomic writes data to a temporary file next to filename and renames it into place,
+// so other tools reading the file never see it half-written
+func writeFileAtomic(filename string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp*")
+	if err != nil {
+		return err
 	}
+	// Removing fails harmlessly once the rename has happened
+	defer os.Remove(tmp.Name())
 
-	return altNames, nil
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
 }
 
-// extractQuotedField extracts content from a quoted field
-func extractQuotedField(field string) (string, error) {
-	field = strings.TrimSpace(field)
-	if len(field) < 2 {
-		return "", fmt.Errorf("field too short to contain quotes")
+// writeRoleBaseline writes each player's roles in the roles file format, so it can be read back with signup.ParseRolesFile
+func writeRoleBaseline(filename string, players []signup.Player) error {
+	var b strings.Builder
+	b.WriteString("# Role baseline: Username:Role1;Role2\n")
+	for _, player := range players {
+		fmt.Fprintf(&b, "%s:%s\n", player.Username, player.Roles)
 	}
 
-	if !strings.HasPrefix(field, "\"") || !strings.HasSuffix(field, "\"") {
-		return "", fmt.Errorf("field is not properly quoted")
+	if err := writeFileAtomic(filename, []byte(b.String())); err != nil {
+		return fmt.Errorf("failed to write role baseline: %w", err)
 	}
+	return nil
+}
 
-	// Remove surrounding quotes
-	return field[1 : len(field)-1], nil
+// rosterHistorySize is the number of recent runs whose member counts are averaged
+const rosterHistorySize = 5
+
+// RosterRun is the member count of one earlier run, kept in the roster history file
+type RosterRun struct {
+	Timestamp string
+	Members   int
 }
 
-// parseSheetFile reads and parses the sheet.txt file
-func parseSheetFile(filename string) ([]string, error) {
-	file, err := os.Open(filename)
+// readRosterHistory reads the roster history file of "timestamp count" lines;
+// a missing file only means there is no history yet
+func readRosterHistory(fsys fs.FS, filename string) ([]RosterRun, error) {
+	file, err := fsys.Open(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to open sheet file: %w", err)
+		return nil, fmt.Errorf("failed to open roster history: %w", err)
 	}
 	defer file.Close()
 
-	var names []string
+	var runs []RosterRun
 	scanner := bufio.NewScanner(file)
-
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
-
-		// Skip empty lines
-		if line == "" {
+		if line == "" || strings.HasPrefix(line, "#") {
 			continue
 		}
 
-		// Clean the name (remove parentheses content and extra spaces)
-		cleanName := cleanPlayerName(line)
-		if cleanName != "" {
-			names = append(names, cleanName)
+		var run RosterRun
+		if _, err := fmt.Sscan(line, &run.Timestamp, &run.Members); err != nil {
+			log.Printf("Warning: skipping malformed roster history line: %s", line)
+			continue
 		}
+		runs = append(runs, run)
 	}
 
 	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading sheet file: %w", err)
+		return nil, fmt.Errorf("error reading roster history: %w", err)
 	}
 
-	return names, nil
+	return runs, nil
 }
 
-// cleanPlayerName removes parentheses content and normalizes the name
-func cleanPlayerName(name string) string {
-	// Remove content in parentheses (e.g., "(realm)", "(Longbow)")
-	re := regexp.MustCompile(`\s*\([^)]*\)\s*`)
-	cleaned := re.ReplaceAllString(name, "")
+// writeRosterHistory writes the most recent rosterHistorySize runs back to the roster history file
+func writeRosterHistory(filename string, runs []RosterRun) error {
+	if len(runs) > rosterHistorySize {
+		runs = runs[len(runs)-rosterHistorySize:]
+	}
 
-	// Remove extra whitespace
-	cleaned = strings.TrimSpace(cleaned)
+	var b strings.Builder
+	b.WriteString("# Guild members per run: timestamp count\n")
+	for _, run := range runs {
+		fmt.Fprintf(&b, "%s %d\n", run.Timestamp, run.Members)
+	}
 
-	// Skip obviously invalid entries
-	if strings.Contains(strings.ToLower(cleaned), "delete") ||
-		strings.Contains(strings.ToLower(cleaned), "spam") ||
-		strings.Contains(strings.ToLower(cleaned), "mess") ||
-		strings.Contains(strings.ToLower(cleaned), "pedo") {
-		return ""
+	if err := writeFileAtomic(filename, []byte(b.String())); err != nil {
+		return fmt.Errorf("failed to write roster history: %w", err)
 	}
+	return nil
+}
 
-	return cleaned
+// DeltaRun is what the previous -delta-only run found, kept in the delta file to compare with
+type DeltaRun struct {
+	Timestamp       string   `json:"timestamp"`
+	Missing         []string `json:"missing"`
+	SheetNotInGuild []string `json:"sheetNotInGuild"`
 }
 
-// findNameMatch checks if a guild name exists in the sheet names, using alternative names
-func findNameMatch(guildName string, sheetNames []string, altNames *AlternativeNames, ignoredNames []string) MatchResult {
-	guildNameLower := strings.ToLower(guildName)
+// readDeltaRun reads the previous run from the delta file; a missing file means this is the first run
+func readDeltaRun(fsys fs.FS, filename string) (*DeltaRun, error) {
+	data, err := fs.ReadFile(fsys, filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read previous run: %w", err)
+	}
 
-	// Check direct match first
-	for _, sheetName := range sheetNames {
-		if strings.ToLower(sheetName) == guildNameLower {
-			return MatchResult{
-				Found:     true,
-				GuildName: guildName,
-				MatchType: "direct",
-			}
-		}
+	var run DeltaRun
+	if err := json.Unmarshal(data, &run); err != nil {
+		return nil, fmt.Errorf("failed to parse previous run in %s: %w", filename, err)
 	}
+	return &run, nil
+}
 
-	// Check alternative names
-	if alternatives, exists := altNames.GuildToAlternatives[guildName]; exists {
-		for _, alt := range alternatives {
-			altLower := strings.ToLower(alt)
-			for _, sheetName := range sheetNames {
-				if strings.ToLower(sheetName) == altLower {
-					return MatchResult{
-						Found:           true,
-						GuildName:       guildName,
-						AlternativeName: alt,
-						MatchType:       "alternative",
-					}
-				}
-			}
-		}
+// writeDeltaRun replaces the delta file with this run, for the next -delta-only run to compare with
+func writeDeltaRun(filename string, run DeltaRun) error {
+	data, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode run: %w", err)
 	}
+	if err := writeFileAtomic(filename, append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write delta file: %w", err)
+	}
+	return nil
+}
 
-	// Check ignored patterns (legacy support)
-	for _, sheetName := range sheetNames {
-		for _, ignored := range ignoredNames {
-			ignoredLower := strings.ToLower(ignored)
-			if strings.Contains(guildNameLower, ignoredLower) && strings.Contains(strings.ToLower(sheetName), ignoredLower) {
-				return MatchResult{
-					Found:           true,
-					GuildName:       guildName,
-					AlternativeName: sheetName,
-					MatchType:       "ignored",
-				}
+// deltaLines lists what changed between two runs: players who went missing or are no longer
+// missing, then sheet names that stopped or started matching the guild
+func deltaLines(previous DeltaRun, current DeltaRun, opts ReportOptions) []string {
+	// added returns the names of to that aren't in from, in the order of to
+	added := func(from []string, to []string) []string {
+		known := make(map[string]bool)
+		for _, name := range from {
+			known[name] = true
+		}
+		var names []string
+		for _, name := range to {
+			if !known[name] {
+				names = append(names, name)
 			}
 		}
+		return names
 	}
 
-	return MatchResult{Found: false}
+	var lines []string
+	for _, name := range added(previous.Missing, current.Missing) {
+		lines = append(lines, fmt.Sprintf("+ %s now missing", mentionName(name, opts)))
+	}
+	for _, name := range added(current.Missing, previous.Missing) {
+		lines = append(lines, fmt.Sprintf("- %s signed up or went offline", displayName(name, opts.TitleCase)))
+	}
+	for _, name := range added(previous.SheetNotInGuild, current.SheetNotInGuild) {
+		lines = append(lines, fmt.Sprintf("+ %s now in sheet but not in guild", displayName(name, opts.TitleCase)))
+	}
+	for _, name := range added(current.SheetNotInGuild, previous.SheetNotInGuild) {
+		lines = append(lines, fmt.Sprintf("- %s no longer in sheet but not in guild", displayName(name, opts.TitleCase)))
+	}
+	return lines
 }
 
-// findSheetNameMatch checks if a sheet name exists in guild names, using alternative names
-func findSheetNameMatch(sheetName string, guildNames []string, altNames *AlternativeNames, ignoredNames []string) MatchResult {
-	sheetNameLower := strings.ToLower(sheetName)
-
-	// Check direct match first
-	for _, guildName := range guildNames {
-		if strings.ToLower(guildName) == sheetNameLower {
-			return MatchResult{
-				Found:     true,
-				GuildName: guildName,
-				MatchType: "direct",
-			}
-		}
+// rosterShortfall returns the average member count of the given runs and whether members
+// is more than minFraction below it, which usually means the guild export was cut off
+func rosterShortfall(runs []RosterRun, members int, minFraction float64) (float64, bool) {
+	if len(runs) == 0 {
+		return 0, false
 	}
-
-	// Check if sheet name is an alternative name
-	if guildName, exists := altNames.AlternativeToGuild[sheetNameLower]; exists {
-		// Verify the guild name actually exists in the guild list
-		for _, name := range guildNames {
-			if name == guildName {
-				return MatchResult{
-					Found:           true,
-					GuildName:       guildName,
-					AlternativeName: sheetName,
-					MatchType:       "alternative",
-				}
-			}
-		}
+	total := 0
+	for _, run := range runs {
+		total += run.Members
 	}
+	average := float64(total) / float64(len(runs))
+	return average, float64(members) < average*(1-minFraction)
+}
 
-	// Check ignored patterns (legacy support)
-	for _, guildName := range guildNames {
-		for _, ignored := range ignoredNames {
-			ignoredLower := strings.ToLower(ignored)
-			if strings.Contains(sheetNameLower, ignoredLower) && strings.Contains(strings.ToLower(guildName), ignoredLower) {
-				return MatchResult{
-					Found:           true,
-					GuildName:       guildName,
-					AlternativeName: sheetName,
-					MatchType:       "ignored",
-				}
-			}
+// expandSheetFiles turns the -sheet value into file names: a comma-separated list whose
+// entries may be globs like data/sheet-*.txt, each of which must match at least one file in fsys
+func expandSheetFiles(fsys fs.FS, value string) ([]string, error) {
+	var files []string
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.ContainsAny(entry, "*?[") {
+			files = append(files, entry)
+			continue
+		}
+		// fs.Glob takes slash-separated patterns, also on Windows
+		matches, err := fs.Glob(fsys, filepath.ToSlash(entry))
+		if err != nil {
+			return nil, fmt.Errorf("invalid sheet pattern %q: %w", entry, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no sheet files match %q", entry)
 		}
+		files = append(files, matches...)
 	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no sheet file given")
+	}
+	return files, nil
+}
+
+// googleSheetsScope is the read-only access the service account asks for
+const googleSheetsScope = "https://www.googleapis.com/auth/spreadsheets.readonly"
+
+// googleSheetsAPI is the base URL of the Sheets API spreadsheets
+const googleSheetsAPI = "https://sheets.googleapis.com/v4/spreadsheets"
 
-	return MatchResult{Found: false}
+// googleSheet is a range of a Google Sheet read with -gsheet instead of a local sheet file
+type googleSheet struct {
+	SpreadsheetID string
+	Range         string // A1 notation like "Roster!B2:B"; every non-empty cell is a sheet entry
+	Credentials   string // service account key file downloaded from the Google Cloud console
+	Cache         string // the last successful fetch, in the sheet.txt format
+	API           string // base URL of the spreadsheets API; empty means googleSheetsAPI
 }
 
-// getExcludedRoles returns a list of roles that should be excluded from results
-func getExcludedRoles() []string {
-	return []string{
-		"Bomber",
-		"Guild Master",
-	}
+// serviceAccountKey holds the fields of a service account key file needed to get an access token
+type serviceAccountKey struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
 }
 
-// getIgnoredNames returns a list of names/partial names that should be ignored in matching
-func getIgnoredNames() []string {
-	return []string{
-		"sarge",
+// syncGoogleSheet fetches the sheet's range into its cache file, one entry per line, so the
+// cache is then read like any sheet file. When the fetch fails the previous copy is kept and
+// used with a warning, so a transient API error doesn't break the run. The credentials and the
+// cache are read through fsys.
+func syncGoogleSheet(ctx context.Context, fsys fs.FS, clock Clock, client *http.Client, sheet googleSheet, progress io.Writer) error {
+	entries, err := fetchGoogleSheet(ctx, fsys, clock, client, sheet)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		info, statErr := fs.Stat(fsys, sheet.Cache)
+		if statErr != nil {
+			return err
+		}
+		log.Printf("Warning: %v; using the copy fetched at %s from %s", err, info.ModTime().Format(signup.DefaultLastOnlineLayout), sheet.Cache)
+		return nil
+	}
+	fmt.Fprintf(progress, "Fetched %d entries from Google Sheet %s\n", len(entries), sheet.SpreadsheetID)
+
+	var content strings.Builder
+	for _, entry := range entries {
+		content.WriteString(entry)
+		content.WriteString("\n")
 	}
+	if err := writeFileAtomic(sheet.Cache, []byte(content.String())); err != nil {
+		return fmt.Errorf("failed to write Google Sheet cache: %w", err)
+	}
+	return nil
 }
 
-// hasExcludedRole checks if a player has any of the excluded roles
-func hasExcludedRole(playerRoles string, excludedRoles []string) bool {
-	if playerRoles == "" {
-		return false
+// fetchGoogleSheet reads the non-empty cells of the sheet's range row by row through the Sheets API
+func fetchGoogleSheet(ctx context.Context, fsys fs.FS, clock Clock, client *http.Client, sheet googleSheet) ([]string, error) {
+	token, err := googleAccessToken(ctx, fsys, clock, client, sheet.Credentials)
+	if err != nil {
+		return nil, err
 	}
 
-	// Split roles by semicolon
-	roles := strings.Split(playerRoles, ";")
-
-	// Create a map of player's roles for quick lookup (case-insensitive)
-	playerRoleMap := make(map[string]bool)
-	for _, role := range roles {
-		cleanRole := strings.TrimSpace(role)
-		if cleanRole != "" {
-			playerRoleMap[strings.ToLower(cleanRole)] = true
-		}
+	api := sheet.API
+	if api == "" {
+		api = googleSheetsAPI
+	}
+	endpoint := fmt.Sprintf("%s/%s/values/%s", api, url.PathEscape(sheet.SpreadsheetID), url.PathEscape(sheet.Range))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch Google Sheet: %w", err)
 	}
+	req.Header.Set("Authorization", "Bearer "+token)
 
-	// Check if any excluded role is present
-	for _, excludedRole := range excludedRoles {
-		if playerRoleMap[strings.ToLower(excludedRole)] {
-			return true
-		}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch Google Sheet: %w", err)
 	}
+	defer resp.Body.Close()
 
-	return false
-}
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("Google Sheets API returned %s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
 
-// findOnlinePlayersNotInSheet finds players who are online but not in the sheet and don't have excluded roles
-func findOnlinePlayersNotInSheet(guildPlayers []Player, sheetNames []string, altNames *AlternativeNames) ([]string, []string, []MatchResult) {
-	excludedRoles := getExcludedRoles()
-	ignoredNames := getIgnoredNames()
-	var result []string
-	var excluded []string
-	var matches []MatchResult
+	// Values are the formatted cell texts, rows without trailing empty cells
+	var body struct {
+		Values [][]string `json:"values"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to read Google Sheet: %w", err)
+	}
 
-	for _, player := range guildPlayers {
-		// Check if player is online
-		if player.Status == "Online" {
-			// Check if player is NOT in sheet (using improved name matching)
-			matchResult := findNameMatch(player.Username, sheetNames, altNames, ignoredNames)
-			if !matchResult.Found {
-				// Check if player has excluded roles
-				if hasExcludedRole(player.Roles, excludedRoles) {
-					excluded = append(excluded, player.Username)
-				} else {
-					result = append(result, player.Username)
-				}
-			} else {
-				// Player was found in sheet, record the match
-				matches = append(matches, matchResult)
+	var entries []string
+	for _, row := range body.Values {
+		for _, cell := range row {
+			if strings.TrimSpace(cell) != "" {
+				entries = append(entries, cell)
 			}
 		}
 	}
-
-	return result, excluded, matches
+	return entries, nil
 }
 
-// findSheetPlayersNotInGuild finds players who are in the sheet but not in the guild
-func findSheetPlayersNotInGuild(guildPlayers []Player, sheetNames []string, altNames *AlternativeNames) ([]string, []MatchResult) {
-	ignoredNames := getIgnoredNames()
-	var result []string
-	var matches []MatchResult
+// googleAccessToken signs a JWT with the service account's key and trades it for an access
+// token, the OAuth flow Google uses for server-to-server calls
+func googleAccessToken(ctx context.Context, fsys fs.FS, clock Clock, client *http.Client, credentialsFile string) (string, error) {
+	data, err := fs.ReadFile(fsys, credentialsFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read Google credentials: %w", err)
+	}
+	var key serviceAccountKey
+	if err := json.Unmarshal(data, &key); err != nil {
+		return "", fmt.Errorf("failed to parse Google credentials %s: %w", credentialsFile, err)
+	}
+	if key.TokenURI == "" {
+		key.TokenURI = "https://oauth2.googleapis.com/token"
+	}
 
-	// Create list of all guild player names
-	var guildNames []string
-	for _, player := range guildPlayers {
-		guildNames = append(guildNames, player.Username)
+	block, _ := pem.Decode([]byte(key.PrivateKey))
+	if block == nil {
+		return "", fmt.Errorf("Google credentials %s have no private key", credentialsFile)
+	}
+	parsedKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse the private key of Google credentials %s: %w", credentialsFile, err)
+	}
+	rsaKey, ok := parsedKey.(*rsa.PrivateKey)
+	if !ok {
+		return "", fmt.Errorf("the private key of Google credentials %s is not an RSA key", credentialsFile)
 	}
 
-	for _, sheetName := range sheetNames {
-		// Check if sheet player is NOT in guild (using improved name matching)
-		matchResult := findSheetNameMatch(sheetName, guildNames, altNames, ignoredNames)
-		if !matchResult.Found {
-			result = append(result, sheetName)
-		} else {
-			// Sheet player was found in guild, record the match
-			matches = append(matches, matchResult)
-		}
+	// The token request is a JWT signed with RS256, valid for an hour
+	now := clock.Now()
+	claims, err := json.Marshal(map[string]any{
+		"iss":   key.ClientEmail,
+		"scope": googleSheetsScope,
+		"aud":   key.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode Google token request: %w", err)
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`)) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign Google token request: %w", err)
 	}
 
-	return result, matches
-}
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, key.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to request Google access token: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-func main() {
-	// Parse alternative names file
-	fmt.Println("Loading alternative name mappings...")
-	altNames, err := parseAlternativeNamesFile("data/sheet-names.txt")
+	resp, err := client.Do(req)
 	if err != nil {
-		log.Fatalf("Error parsing alternative names file: %v", err)
+		return "", fmt.Errorf("failed to request Google access token: %w", err)
 	}
-	fmt.Printf("Loaded %d alternative name mappings\n", len(altNames.GuildToAlternatives))
+	defer resp.Body.Close()
 
-	// Parse guild file
-	fmt.Println("Reading guild data...")
-	guildPlayers, err := parseGuildFile("data/guild.txt")
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("Google token endpoint returned %s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("failed to read Google access token: %w", err)
+	}
+	if token.AccessToken == "" {
+		return "", errors.New("Google token endpoint returned no access token")
+	}
+	return token.AccessToken, nil
+}
+
+// checkFileAge returns an error if the file was last modified longer ago than maxAge
+func checkFileAge(fsys fs.FS, clock Clock, filename string, maxAge time.Duration) error {
+	info, err := fs.Stat(fsys, filename)
 	if err != nil {
-		log.Fatalf("Error parsing guild file: %v", err)
+		return fmt.Errorf("failed to check age of %s: %w", filename, err)
 	}
-	fmt.Printf("Processed %d players from guild.txt\n", len(guildPlayers))
 
-	// Count online players
-	onlineCount := 0
-	for _, player := range guildPlayers {
-		if player.Status == "Online" {
-			onlineCount++
+	age := clock.Now().Sub(info.ModTime())
+	if age > maxAge {
+		return fmt.Errorf("%s was last modified %s ago, which is older than the allowed %s; refresh the export and try again",
+			filename, age.Round(time.Second), maxAge)
+	}
+
+	return nil
+}
+
+// analysisInputs are the data files read before the guild and sheet, kept together so -serve
+// can read them again for every request
+type analysisInputs struct {
+	Sections       map[string]string // sections of the -combined file, nil without one
+	AltNames       *signup.AlternativeNames
+	IgnoredNames   []string
+	IgnorePatterns []*regexp.Regexp
+}
+
+// loadAnalysisInputs reads the combined file, the alternative names and the optional data files,
+// filling in the excluded roles and the blocklist of the options
+func loadAnalysisInputs(fsys fs.FS, combined string, altNamesFile string, cleanOpts *signup.CleanOptions, matchOpts *signup.MatchOptions, progress io.Writer) (analysisInputs, error) {
+	var inputs analysisInputs
+	var err error
+
+	// A combined file replaces the three separate data files
+	if combined != "" {
+		inputs.Sections, err = signup.ParseCombinedFile(fsys, combined)
+		if err != nil {
+			return inputs, err
 		}
 	}
-	fmt.Printf("Found %d online players in guild\n", onlineCount)
 
-	// Parse sheet file
-	fmt.Println("Reading sheet data...")
-	sheetNames, err := parseSheetFile("data/sheet.txt")
+	fmt.Fprintln(progress, "Loading alternative name mappings...")
+	if inputs.Sections != nil {
+		// A missing [aliases] section is treated like a missing file
+		inputs.AltNames, err = signup.ParseAlternativeNames(strings.NewReader(inputs.Sections["aliases"]))
+	} else {
+		inputs.AltNames, err = signup.ParseAlternativeNamesFile(fsys, altNamesFile)
+	}
 	if err != nil {
-		log.Fatalf("Error parsing sheet file: %v", err)
+		return inputs, fmt.Errorf("failed to parse alternative names file: %w", err)
 	}
-	fmt.Printf("Processed %d player names from sheet.txt\n", len(sheetNames))
+	fmt.Fprintf(progress, "Loaded %d alternative name mappings\n", len(inputs.AltNames.GuildToAlternatives))
 
-	// Find players online but not in sheet
-	fmt.Println("Analyzing data...")
-	missingPlayers, excludedPlayers, guildMatches := findOnlinePlayersNotInSheet(guildPlayers, sheetNames, altNames)
+	// Officers change the excluded ranks often, so they live in a file rather than the code
+	matchOpts.ExcludedRoles, err = signup.ParseExcludedRolesFile(fsys, "data/excluded-roles.txt")
+	if err != nil {
+		return inputs, fmt.Errorf("failed to parse excluded roles file: %w", err)
+	}
 
-	// Find players in sheet but not in guild
-	sheetPlayersNotInGuild, sheetMatches := findSheetPlayersNotInGuild(guildPlayers, sheetNames, altNames)
+	inputs.IgnoredNames, err = signup.ParseIgnoredNamesFile(fsys, "data/ignored-names.txt")
+	if err != nil {
+		return inputs, fmt.Errorf("failed to parse ignored names file: %w", err)
+	}
 
-	// Show successful matches first
-	if len(guildMatches) > 0 {
-		fmt.Printf("\n=== SUCCESSFUL MATCHES ===\n")
-		directMatches := 0
-		alternativeMatches := 0
-		ignoredMatches := 0
+	cleanOpts.Blocklist, err = signup.ParseBlocklistFile(fsys, "data/blocklist.txt")
+	if err != nil {
+		return inputs, fmt.Errorf("failed to parse blocklist file: %w", err)
+	}
 
-		for _, match := range guildMatches {
-			switch match.MatchType {
-			case "direct":
-				directMatches++
-			case "alternative":
-				fmt.Printf("Matched: %s (found as '%s' in sheet)\n", match.GuildName, match.AlternativeName)
-				alternativeMatches++
-			case "ignored":
-				fmt.Printf("Matched: %s (pattern match with '%s' in sheet)\n", match.GuildName, match.AlternativeName)
-				ignoredMatches++
+	// Names matching an ignore pattern, such as bot or placeholder accounts, are left out entirely
+	inputs.IgnorePatterns, err = signup.ParseIgnorePatternsFile(fsys, "data/ignore-patterns.txt")
+	if err != nil {
+		return inputs, fmt.Errorf("failed to parse ignore patterns file: %w", err)
+	}
+
+	return inputs, nil
+}
+
+// readGuildPlayers parses the guild from the combined file, stdin for "-" or the guild file,
+// leaving out members matching an ignore pattern, and spells the mapped guild names as the roster does
+// unless case tells members apart; reading stdin stops when ctx is cancelled
+func readGuildPlayers(ctx context.Context, fsys fs.FS, inputs analysisInputs, guildFile string, guildOpts signup.GuildOptions, caseSensitive bool, progress io.Writer) ([]signup.Player, error) {
+	var guildPlayers []signup.Player
+	var err error
+	if inputs.Sections != nil {
+		content, exists := inputs.Sections["guild"]
+		if !exists {
+			return nil, errors.New("combined file has no [guild] section")
+		}
+		guildPlayers, err = signup.ParseGuild(strings.NewReader(content), guildOpts)
+	} else if guildFile == "-" {
+		// Pasted data, e.g. pbpaste | signup-checker -guild -
+		guildPlayers, err = signup.ParseGuild(contextFile{File: os.Stdin, ctx: ctx}, guildOpts)
+	} else {
+		guildPlayers, err = signup.ParseGuildFile(fsys, guildFile, guildOpts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(progress, "Processed %d players from %s\n", len(guildPlayers), guildFile)
+
+	if len(inputs.IgnorePatterns) > 0 {
+		var keptPlayers []signup.Player
+		for _, player := range guildPlayers {
+			if pattern := signup.MatchingIgnorePattern(player.Username, inputs.IgnorePatterns); pattern != nil {
+				signup.DebugLog.Printf("Ignoring guild member %s (matches pattern %s)", player.Username, pattern)
+				continue
 			}
+			keptPlayers = append(keptPlayers, player)
 		}
+		fmt.Fprintf(progress, "Ignored %d guild members matching ignore patterns\n", len(guildPlayers)-len(keptPlayers))
+		guildPlayers = keptPlayers
+	}
 
-		fmt.Printf("- Direct matches: %d\n", directMatches)
-		fmt.Printf("- Alternative name matches: %d\n", alternativeMatches)
-		if ignoredMatches > 0 {
-			fmt.Printf("- Pattern matches: %d\n", ignoredMatches)
-		}
+	// Mappings written as "xsarge:..." still apply to the member xSarge
+	if !caseSensitive {
+		inputs.AltNames.UseRosterSpelling(guildPlayers)
 	}
 
-	// Output results
-	fmt.Printf("\n=== RESULTS ===\n")
-	fmt.Printf("Players online but not in sheet (%d):\n", len(missingPlayers))
+	return guildPlayers, nil
+}
 
-	if len(missingPlayers) == 0 {
-		fmt.Println("  (none)")
+// checkAltNames warns about alternative names that point to names outside the guild or that
+// conflict, and with strict returns an error for them instead; the one-shot run and -serve
+// both check them so a request can't pass what the command line rejects
+func checkAltNames(altNames *signup.AlternativeNames, guildPlayers []signup.Player, strict bool) error {
+	// Aliases of people who aren't members can't match anything, which is worth knowing
+	dangling := signup.FindDanglingAliases(altNames, guildPlayers)
+	for _, guildName := range dangling {
+		log.Printf("Warning: alternative names %s point to %s, who is not a guild member",
+			strings.Join(altNames.GuildToAlternatives[guildName], ", "), guildName)
+	}
+	if strict && len(dangling) > 0 {
+		return fmt.Errorf("%d alternative name mappings point to names not in the guild (-strict-altnames)", len(dangling))
+	}
+
+	// Alternative names shared between members or with a real username match unpredictably
+	conflicts := signup.FindAliasConflicts(altNames, guildPlayers)
+	for _, conflict := range conflicts {
+		log.Printf("Warning: %s", conflict)
+	}
+	if strict && len(conflicts) > 0 {
+		return fmt.Errorf("%d alternative name conflicts (-strict-altnames)", len(conflicts))
+	}
+	return nil
+}
+
+// readSheetNames parses the sheet from the combined file or the sheet files, leaving out entries
+// matching an ignore pattern and, unless keepDuplicates is set, names listed more than once;
+// sheetFile is the -sheet value the files were expanded from
+func readSheetNames(fsys fs.FS, inputs analysisInputs, sheetFile string, sheetFiles []string, cleanOpts signup.CleanOptions, keepDuplicates bool, caseSensitive bool, progress io.Writer) ([]string, error) {
+	var sheetNames []string
+	var err error
+	if inputs.Sections != nil {
+		content, exists := inputs.Sections["sheet"]
+		if !exists {
+			return nil, errors.New("combined file has no [sheet] section")
+		}
+		sheetNames, err = signup.ParseSheet(strings.NewReader(content), cleanOpts)
 	} else {
-		for i, player := range missingPlayers {
-			if i == len(missingPlayers)-1 {
-				fmt.Printf("  %s\n", player)
-			} else {
-				fmt.Printf("  %s,\n", player)
+		sheetNames, err = signup.ParseSheetFiles(fsys, sheetFiles, cleanOpts, caseSensitive)
+	}
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(progress, "Processed %d player names from %s\n", len(sheetNames), sheetFile)
+
+	if len(inputs.IgnorePatterns) > 0 {
+		var keptNames []string
+		for _, name := range sheetNames {
+			if pattern := signup.MatchingIgnorePattern(name, inputs.IgnorePatterns); pattern != nil {
+				signup.DebugLog.Printf("Ignoring sheet entry %s (matches pattern %s)", name, pattern)
+				continue
 			}
+			keptNames = append(keptNames, name)
 		}
+		fmt.Fprintf(progress, "Ignored %d sheet entries matching ignore patterns\n", len(sheetNames)-len(keptNames))
+		sheetNames = keptNames
 	}
 
-	// Show excluded players
-	if len(excludedPlayers) > 0 {
-		fmt.Printf("\nExcluded players (have special roles) (%d):\n", len(excludedPlayers))
-		for i, player := range excludedPlayers {
-			if i == len(excludedPlayers)-1 {
-				fmt.Printf("  %s\n", player)
-			} else {
-				fmt.Printf("  %s,\n", player)
-			}
+	// A name signed up twice is still one player
+	if !keepDuplicates {
+		var duplicates []string
+		sheetNames, duplicates = signup.DedupeNames(sheetNames, caseSensitive)
+		if len(duplicates) > 0 {
+			log.Printf("Warning: %d sheet names are listed more than once and counted once: %s", len(duplicates), strings.Join(duplicates, ", "))
 		}
 	}
 
-	// Show players in sheet but not in guild
-	if len(sheetPlayersNotInGuild) > 0 {
-		fmt.Printf("\nPlayers in sheet but not in guild (%d):\n", len(sheetPlayersNotInGuild))
-		for i, player := range sheetPlayersNotInGuild {
-			if i == len(sheetPlayersNotInGuild)-1 {
-				fmt.Printf("  %s\n", player)
-			} else {
-				fmt.Printf("  %s,\n", player)
-			}
+	return sheetNames, nil
+}
+
+// countOnline returns how many players have one of the online statuses
+func countOnline(players []signup.Player, onlineStatuses []string) int {
+	count := 0
+	for _, player := range players {
+		if player.IsOnline(onlineStatuses) {
+			count++
 		}
 	}
+	return count
+}
 
-	// Show sheet matches if any
-	/*
-		if len(sheetMatches) > 0 {
-			fmt.Printf("\nSheet name matches found (%d):\n", len(sheetMatches))
-			for _, match := range sheetMatches {
-				if match.MatchType == "alternative" {
-					fmt.Printf("  '%s' in sheet -> %s in guild\n", match.AlternativeName, match.GuildName)
-				}
-			}
-		}
-	*/
+// reportSettings are the flags that add sections to a report beyond ReportOptions
+type reportSettings struct {
+	Expected      int                     // signups expected with -expected, 0 if not set
+	RoleBaseline  map[string]string       // roles from -role-baseline, nil if not set
+	GroupAllRoles bool                    // with GroupByRole, list players under each of their roles
+	Suggest       bool                    // add the closest guild name of misspelled sheet names
+	AbsentSince   time.Duration           // flag online members last seen longer ago than this, 0 disables
+	GuildStats    *signup.GuildParseStats // line counts of the guild file, shown in the summary when set
+	Sort          string                  // order of the missing, excluded and not-in-guild lists, one of signup.SortOrders
+}
 
-	fmt.Printf("\nSummary:\n")
-	fmt.Printf("- Total guild members: %d\n", len(guildPlayers))
-	fmt.Printf("- Online guild members: %d\n", onlineCount)
-	fmt.Printf("- Players in sheet: %d\n", len(sheetNames))
-	fmt.Printf("- Successful matches: %d\n", len(guildMatches)+len(sheetMatches))
-	fmt.Printf("- Online players missing from sheet: %d\n", len(missingPlayers))
-	fmt.Printf("- Excluded players (special roles): %d\n", len(excludedPlayers))
-	fmt.Printf("- Sheet players not in guild: %d\n", len(sheetPlayersNotInGuild))
-
-	// Wait for user input if running from GUI (Windows Explorer double-click)
-	waitForUserInput()
+// buildReport matches the guild against the sheet and assembles the report with the sections
+// selected by opts and settings; opts.AbsentSince is only kept when the guild has last online times.
+// Matching stops with the context's error when ctx is cancelled.
+func buildReport(ctx context.Context, guildPlayers []signup.Player, sheetNames []string, inputs analysisInputs, matchOpts signup.MatchOptions, opts *ReportOptions, settings reportSettings) (Report, error) {
+	// Find players online but not in sheet, and players in sheet but not in guild
+	result, err := signup.AnalyzeContext(ctx, guildPlayers, sheetNames,
+		signup.GuildNameMatcher{AltNames: inputs.AltNames, IgnoredNames: inputs.IgnoredNames, Opts: matchOpts},
+		signup.SheetNameMatcher{AltNames: inputs.AltNames, IgnoredNames: inputs.IgnoredNames, Opts: matchOpts},
+		matchOpts)
+	if err != nil {
+		return Report{}, err
+	}
+	signup.SortResult(&result, settings.Sort)
+
+	report := Report{
+		MissingPlayers:         result.MissingPlayers,
+		ExcludedPlayers:        result.ExcludedPlayers,
+		SheetPlayersNotInGuild: result.SheetPlayersNotInGuild,
+		Matches:                result.GuildMatches,
+		Summary: ReportSummary{
+			TotalMembers:      len(guildPlayers),
+			OnlineMembers:     countOnline(guildPlayers, matchOpts.OnlineStatuses),
+			SheetPlayers:      len(sheetNames),
+			SuccessfulMatches: len(result.GuildMatches) + len(result.SheetMatches),
+			Missing:           len(result.MissingPlayers),
+			Excluded:          len(result.ExcludedPlayers),
+			SheetNotInGuild:   len(result.SheetPlayersNotInGuild),
+			Expected:          settings.Expected,
+			SignedUp:          len(result.GuildMatches),
+			Eligible:          len(result.GuildMatches) + len(result.MissingPlayers),
+			GuildLines:        settings.GuildStats,
+		},
+	}
+	if settings.RoleBaseline != nil {
+		report.RoleChanges = signup.FindRoleChanges(guildPlayers, settings.RoleBaseline)
+	}
+	if opts.RequiredRole != "" {
+		report.MissingRequiredRole = signup.FindMissingRequiredRole(guildPlayers, opts.RequiredRole, matchOpts.OnlineStatuses)
+	}
+	if opts.GroupByRole {
+		report.MissingByRole = signup.GroupByRole(result.MissingPlayers, settings.GroupAllRoles)
+	}
+	if settings.Suggest {
+		report.DidYouMean = signup.FindDidYouMean(result.SheetPlayersNotInGuild, signup.Usernames(guildPlayers))
+	}
+	if opts.MissReasons {
+		report.MissReasons = signup.ClassifyMisses(signup.Usernames(result.MissingPlayers), result.SheetPlayersNotInGuild)
+	}
+
+	// Flag online statuses that the last online time contradicts, when the export has that column
+	opts.AbsentSince = 0
+	if settings.AbsentSince > 0 {
+		hasLastOnline := false
+		for _, player := range guildPlayers {
+			if !player.LastOnline.IsZero() {
+				hasLastOnline = true
+				break
+			}
+		}
+		if hasLastOnline {
+			opts.AbsentSince = settings.AbsentSince
+			report.StaleOnline = signup.FindStaleOnline(guildPlayers, matchOpts.OnlineStatuses, matchOpts.Now.Add(-settings.AbsentSince))
+		} else {
+			log.Printf("Note: the guild data has no last online times, so -absent-since has no effect")
+		}
+	}
+
+	return report, nil
+}
+
+// Report is everything a run found, in the shape shared by all output formats
+type Report struct {
+	Run                    *RunInfo                `json:"run,omitempty"`
+	MissingPlayers         []signup.Player         `json:"missingPlayers"`
+	ExcludedPlayers        []signup.ExcludedPlayer `json:"excludedPlayers"`
+	SheetPlayersNotInGuild []string                `json:"sheetPlayersNotInGuild"`
+	Matches                []signup.MatchResult    `json:"matches"`
+	RoleChanges            []signup.RoleChange     `json:"roleChanges,omitempty"`
+	MissingRequiredRole    []string                `json:"missingRequiredRole,omitempty"`
+	MissReasons            []signup.MissReason     `json:"missReasons,omitempty"`
+	StaleOnline            []signup.Player         `json:"staleOnline,omitempty"` // online players last seen before -absent-since
+	MissingByRole          []signup.RoleGroup      `json:"missingByRole,omitempty"`
+	DidYouMean             map[string]string       `json:"didYouMean,omitempty"` // closest guild name of misspelled sheet names
+	Summary                ReportSummary           `json:"summary"`
+}
+
+// ReportSummary holds the counts shown at the end of the report
+type ReportSummary struct {
+	TotalMembers      int                     `json:"totalMembers"`
+	OnlineMembers     int                     `json:"onlineMembers"`
+	SheetPlayers      int                     `json:"sheetPlayers"`
+	SuccessfulMatches int                     `json:"successfulMatches"`
+	Missing           int                     `json:"missing"`
+	Excluded          int                     `json:"excluded"`
+	SheetNotInGuild   int                     `json:"sheetNotInGuild"`
+	Expected          int                     `json:"expected,omitempty"`   // signups expected with -expected, 0 if not set
+	SignedUp          int                     `json:"signedUp"`             // online members found in the sheet
+	Eligible          int                     `json:"eligible"`             // online members that should be in the sheet, after exclusions
+	GuildLines        *signup.GuildParseStats `json:"guildLines,omitempty"` // how the guild file's lines were handled
+}
+
+// Compliance returns the share of eligible online members found in the sheet with one decimal,
+// followed by the counts it was computed from
+func (summary ReportSummary) Compliance() string {
+	if summary.Eligible == 0 {
+		return "n/a (no eligible online members)"
+	}
+	percentage := 100 * float64(summary.SignedUp) / float64(summary.Eligible)
+	return fmt.Sprintf("%.1f%% (%d of %d eligible online members)", percentage, summary.SignedUp, summary.Eligible)
+}
+
+// ReportOptions selects the optional sections and styling of the text report
+type ReportOptions struct {
+	TitleCase      bool              // show names in title case
+	ExcludeNoRole  bool              // players without roles were excluded too
+	RoleBaseline   bool              // show role changes since the baseline
+	RequiredRole   string            // show online players lacking this role
+	BySeverity     bool              // list all discrepancies by severity
+	RoleWeights    map[string]int    // how urgent a missing player is by lowercase role, for BySeverity
+	SuggestAliases bool              // suggest alternative names for missing players
+	MissReasons    bool              // explain the likely reason each player is missing
+	AbsentSince    time.Duration     // show online players last seen longer ago than this
+	GroupByRole    bool              // list the missing players under each role
+	ShowMatched    bool              // list every player found in both the guild and the sheet
+	Mentions       map[string]string // Discord user IDs by lowercase username, to ping missing players; nil leaves names as they are
+}
+
+// OutputSink is an extra destination for the report, given as -out PATH[:FORMAT]; the path
+// stdoutSinkPath names stdout, so one run can print text and write JSON to a file
+type OutputSink struct {
+	Path   string
+	Format string
+}
+
+// stdoutSinkPath is the -out path that writes the report to stdout instead of a file
+const stdoutSinkPath = "-"
+
+// outputFormats are the report formats an output sink can use
+var outputFormats = []string{"text", "json", "csv", "markdown"}
+
+// outputSinks collects the repeatable -out flag
+type outputSinks []OutputSink
+
+// String returns the sinks as they were given on the command line
+func (sinks *outputSinks) String() string {
+	var parts []string
+	for _, sink := range *sinks {
+		if sink.Format == "" {
+			parts = append(parts, sink.Path)
+		} else {
+			parts = append(parts, sink.Path+":"+sink.Format)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// Set adds a sink; the format after the last colon is optional and defaults to -format,
+// and anything after a colon that isn't a format stays part of the path (e.g. C:\\report.txt)
+func (sinks *outputSinks) Set(value string) error {
+	sink := OutputSink{Path: value}
+	if i := strings.LastIndex(value, ":"); i >= 0 {
+		for _, format := range outputFormats {
+			if value[i+1:] == format {
+				sink = OutputSink{Path: value[:i], Format: format}
+			}
+		}
+	}
+	if sink.Path == "" {
+		return fmt.Errorf("missing file name in %q", value)
+	}
+	*sinks = append(*sinks, sink)
+	return nil
+}
+
+// withEmptyLists returns the report with nil lists replaced by empty ones, so JSON
+// consumers always get arrays rather than null
+func (report Report) withEmptyLists() Report {
+	if report.MissingPlayers == nil {
+		report.MissingPlayers = []signup.Player{}
+	}
+	if report.ExcludedPlayers == nil {
+		report.ExcludedPlayers = []signup.ExcludedPlayer{}
+	}
+	if report.SheetPlayersNotInGuild == nil {
+		report.SheetPlayersNotInGuild = []string{}
+	}
+	if report.Matches == nil {
+		report.Matches = []signup.MatchResult{}
+	}
+	return report
+}
+
+// writeReport writes the report to w in the given format
+func writeReport(w io.Writer, format string, report Report, opts ReportOptions) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report.withEmptyLists()); err != nil {
+			return fmt.Errorf("failed to encode report: %w", err)
+		}
+	case "csv":
+		if err := writeCSVReport(w, report); err != nil {
+			return fmt.Errorf("failed to write CSV report: %w", err)
+		}
+	case "markdown":
+		writeMarkdownReport(w, report, opts)
+	default:
+		writeTextReport(w, report, opts)
+	}
+	return nil
+}
+
+// writeCSVReport writes one row per name with its category (missing, excluded,
+// sheet-not-in-guild or matched), for pivoting in a spreadsheet
+func writeCSVReport(w io.Writer, report Report) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"category", "username", "matchType", "alternativeName"})
+	for _, player := range report.MissingPlayers {
+		writer.Write([]string{"missing", player.Username, "", ""})
+	}
+	for _, player := range report.ExcludedPlayers {
+		writer.Write([]string{"excluded", player.Username, "", ""})
+	}
+	for _, name := range report.SheetPlayersNotInGuild {
+		writer.Write([]string{"sheet-not-in-guild", name, "", ""})
+	}
+	for _, match := range report.Matches {
+		writer.Write([]string{"matched", match.GuildName, match.MatchType, match.AlternativeName})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// xlsxSheet is one worksheet of an Excel workbook: a header row and rows of cells, where
+// ints and float64s are written as numbers and everything else as text
+type xlsxSheet struct {
+	Name   string
+	Header []string
+	Rows   [][]any
+}
+
+// xlsxReportSheets lays the report out as the worksheets of the -xlsx workbook
+func xlsxReportSheets(report Report, opts ReportOptions) []xlsxSheet {
+	summary := xlsxSheet{Name: "Summary", Header: []string{"Summary", "Players"}, Rows: [][]any{
+		{"Total guild members", report.Summary.TotalMembers},
+		{"Online guild members", report.Summary.OnlineMembers},
+		{"Players in sheet", report.Summary.SheetPlayers},
+		{"Successful matches", report.Summary.SuccessfulMatches},
+		{"Online players missing from sheet", report.Summary.Missing},
+		{"Excluded players", report.Summary.Excluded},
+		{"Sheet players not in guild", report.Summary.SheetNotInGuild},
+		{"Signup compliance", report.Summary.Compliance()},
+	}}
+	if report.Summary.Expected > 0 {
+		summary.Rows = append(summary.Rows, []any{"Expected signups", report.Summary.Expected})
+	}
+	if lines := report.Summary.GuildLines; lines != nil {
+		summary.Rows = append(summary.Rows, []any{"Guild file lines", lines.TotalLines}, []any{"Malformed guild lines", lines.MalformedLines})
+	}
+
+	missing := xlsxSheet{Name: "Missing", Header: []string{"Username", "Status", "Roles", "Last Online"}}
+	for _, player := range report.MissingPlayers {
+		lastOnline := ""
+		if !player.LastOnline.IsZero() {
+			lastOnline = player.LastOnline.Format(signup.DefaultLastOnlineLayout)
+		}
+		missing.Rows = append(missing.Rows, []any{displayName(player.Username, opts.TitleCase), player.Status, player.Roles, lastOnline})
+	}
+
+	excluded := xlsxSheet{Name: "Excluded", Header: []string{"Username", "Roles", "Reason"}}
+	for _, player := range report.ExcludedPlayers {
+		excluded.Rows = append(excluded.Rows, []any{displayName(player.Username, opts.TitleCase), player.Roles, player.Reason})
+	}
+
+	notInGuild := xlsxSheet{Name: "Not in Guild", Header: []string{"Sheet Name", "Did You Mean"}}
+	for _, name := range report.SheetPlayersNotInGuild {
+		notInGuild.Rows = append(notInGuild.Rows, []any{name, report.DidYouMean[name]})
+	}
+
+	matched := xlsxSheet{Name: "Matched", Header: []string{"Username", "Sheet Name", "Match Type", "Confidence", "Method", "Roles", "Loadout"}}
+	for _, match := range report.Matches {
+		matched.Rows = append(matched.Rows, []any{displayName(match.GuildName, opts.TitleCase), match.MatchedName, match.MatchType, match.Confidence, match.Method, match.Roles, match.Loadout})
+	}
+
+	return []xlsxSheet{summary, missing, excluded, notInGuild, matched}
+}
+
+// xlsxColumn returns the column letters of a zero-based column index, e.g. 0 -> A, 26 -> AA
+func xlsxColumn(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// writeXLSXWorksheet writes one worksheet part, with text as inline strings so the workbook
+// needs no shared string table
+func writeXLSXWorksheet(w io.Writer, sheet xlsxSheet) error {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	b.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	header := make([]any, len(sheet.Header))
+	for i, title := range sheet.Header {
+		header[i] = title
+	}
+	for r, row := range append([][]any{header}, sheet.Rows...) {
+		fmt.Fprintf(&b, `<row r="%d">`, r+1)
+		for c, value := range row {
+			ref := xlsxColumn(c) + strconv.Itoa(r+1)
+			switch n := value.(type) {
+			case int:
+				fmt.Fprintf(&b, `<c r="%s"><v>%d</v></c>`, ref, n)
+				continue
+			case float64:
+				fmt.Fprintf(&b, `<c r="%s"><v>%.2f</v></c>`, ref, n)
+				continue
+			}
+			fmt.Fprintf(&b, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">`, ref)
+			xml.EscapeText(&b, []byte(fmt.Sprint(value)))
+			b.WriteString(`</t></is></c>`)
+		}
+		b.WriteString(`</row>`)
+	}
+	b.WriteString(`</sheetData></worksheet>`)
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// writeXLSXReport writes the report as an Excel workbook with a Summary sheet and one sheet per
+// list of players; the format is a zip of XML parts, so it's written directly without a library
+func writeXLSXReport(w io.Writer, report Report, opts ReportOptions) error {
+	sheets := xlsxReportSheets(report, opts)
+
+	var contentTypes, workbook, workbookRels strings.Builder
+	contentTypes.WriteString(xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>`)
+	workbook.WriteString(xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	workbookRels.WriteString(xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for i := range sheets {
+		fmt.Fprintf(&contentTypes, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i+1)
+		fmt.Fprintf(&workbook, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, sheets[i].Name, i+1, i+1)
+		fmt.Fprintf(&workbookRels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i+1, i+1)
+	}
+	contentTypes.WriteString(`</Types>`)
+	workbook.WriteString(`</sheets></workbook>`)
+	workbookRels.WriteString(`</Relationships>`)
+
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", contentTypes.String()},
+		{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`},
+		{"xl/workbook.xml", workbook.String()},
+		{"xl/_rels/workbook.xml.rels", workbookRels.String()},
+	}
+
+	archive := zip.NewWriter(w)
+	for _, part := range parts {
+		file, err := archive.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(file, part.content); err != nil {
+			return err
+		}
+	}
+	for i, sheet := range sheets {
+		file, err := archive.Create(fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1))
+		if err != nil {
+			return err
+		}
+		if err := writeXLSXWorksheet(file, sheet); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// writeOutputSink writes the report to the sink's file in the sink's format, or to stdout
+// for stdoutSinkPath
+func writeOutputSink(stdout io.Writer, sink OutputSink, report Report, opts ReportOptions) error {
+	if sink.Path == stdoutSinkPath {
+		return writeReport(stdout, sink.Format, report, opts)
+	}
+	var b bytes.Buffer
+	if err := writeReport(&b, sink.Format, report, opts); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(sink.Path, b.Bytes()); err != nil {
+		return fmt.Errorf("failed to write %s: %w", sink.Path, err)
+	}
+	return nil
+}
+
+// writeOutputSinks writes the report to every file sink, then to the stdout sinks, so the
+// files are complete whatever else is shown
+func writeOutputSinks(stdout io.Writer, sinks outputSinks, report Report, opts ReportOptions) error {
+	for _, sink := range sinks {
+		if sink.Path == stdoutSinkPath {
+			continue
+		}
+		if err := writeOutputSink(stdout, sink, report, opts); err != nil {
+			return err
+		}
+		fmt.Fprintf(progress, "Wrote %s report to %s\n", sink.Format, sink.Path)
+	}
+	for _, sink := range sinks {
+		if sink.Path == stdoutSinkPath {
+			if err := writeOutputSink(stdout, sink, report, opts); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// defaultReportTemplate reproduces the text report, as a starting point for -template
+//
+//go:embed templates/report.tmpl
+var defaultReportTemplate string
+
+// templateData is what a -template report is executed against: the report itself,
+// the options it was made with, and the matches sorted by guild name
+type templateData struct {
+	Report
+	Opts          ReportOptions
+	SortedMatches []signup.MatchResult
+}
+
+// loadReportTemplate parses the template file, or the embedded default for "default"
+func loadReportTemplate(path string, titleCase bool) (*template.Template, error) {
+	funcs := template.FuncMap{
+		"name": func(username string) string { return displayName(username, titleCase) },
+		"sub":  func(a, b int) int { return a - b },
+		"countType": func(matches []signup.MatchResult, matchType string) int {
+			count := 0
+			for _, match := range matches {
+				if match.MatchType == matchType {
+					count++
+				}
+			}
+			return count
+		},
+	}
+
+	text := defaultReportTemplate
+	if path != "default" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template: %w", err)
+		}
+		text = string(data)
+	}
+
+	tmpl, err := template.New(filepath.Base(path)).Funcs(funcs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// Mention returns a Discord mention for a player with a known Discord ID, and the name otherwise
+func (data templateData) Mention(username string) string {
+	return mentionName(username, data.Opts)
+}
+
+// writeTemplateReport executes a report template
+func writeTemplateReport(w io.Writer, tmpl *template.Template, report Report, opts ReportOptions) error {
+	sortedMatches := make([]signup.MatchResult, len(report.Matches))
+	copy(sortedMatches, report.Matches)
+	sort.SliceStable(sortedMatches, func(i, j int) bool {
+		return strings.ToLower(sortedMatches[i].GuildName) < strings.ToLower(sortedMatches[j].GuildName)
+	})
+
+	data := templateData{Report: report, Opts: opts, SortedMatches: sortedMatches}
+	if err := tmpl.Execute(w, data); err != nil {
+		return fmt.Errorf("failed to execute template: %w", err)
+	}
+	return nil
+}
+
+// markdownCell escapes a value for a Markdown table cell
+func markdownCell(value string) string {
+	return strings.NewReplacer("|", "\\|", "\n", " ").Replace(value)
+}
+
+// writeMarkdownTable writes a Markdown table, or "_None_" when there are no rows
+func writeMarkdownTable(w io.Writer, header []string, rows [][]string) {
+	if len(rows) == 0 {
+		fmt.Fprintln(w, "_None_")
+		return
+	}
+	fmt.Fprintf(w, "| %s |\n", strings.Join(header, " | "))
+	fmt.Fprintf(w, "|%s\n", strings.Repeat("---|", len(header)))
+	for _, row := range rows {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			cells[i] = markdownCell(cell)
+		}
+		fmt.Fprintf(w, "| %s |\n", strings.Join(cells, " | "))
+	}
+}
+
+// writeMarkdownReport writes each result category as a Markdown table with its count in the
+// section title, for pasting into a wiki or Discord
+func writeMarkdownReport(w io.Writer, report Report, opts ReportOptions) {
+	name := func(username string) string {
+		return displayName(username, opts.TitleCase)
+	}
+
+	fmt.Fprintln(w, "# Signup Check")
+	if report.Run != nil {
+		fmt.Fprintf(w, "\nRun %s at %s\n", report.Run.ID, report.Run.Timestamp)
+	}
+
+	fmt.Fprintf(w, "\n## Online but not in sheet (%d)\n\n", len(report.MissingPlayers))
+	var rows [][]string
+	for _, player := range report.MissingPlayers {
+		rows = append(rows, []string{mentionName(player.Username, opts), player.Roles})
+	}
+	writeMarkdownTable(w, []string{"Player", "Roles"}, rows)
+
+	if opts.GroupByRole && len(report.MissingByRole) > 0 {
+		fmt.Fprintf(w, "\n## Missing players by role\n\n")
+		rows = nil
+		for _, group := range report.MissingByRole {
+			role := group.Role
+			if role == "" {
+				role = "(no role)"
+			}
+			var names []string
+			for _, player := range group.Players {
+				names = append(names, name(player.Username))
+			}
+			rows = append(rows, []string{role, fmt.Sprint(len(group.Players)), strings.Join(names, ", ")})
+		}
+		writeMarkdownTable(w, []string{"Role", "Count", "Players"}, rows)
+	}
+
+	if len(report.ExcludedPlayers) > 0 {
+		fmt.Fprintf(w, "\n## Excluded (%d)\n\n", len(report.ExcludedPlayers))
+		rows = nil
+		for _, player := range report.ExcludedPlayers {
+			rows = append(rows, []string{name(player.Username), player.Roles, player.Reason})
+		}
+		writeMarkdownTable(w, []string{"Player", "Roles", "Reason"}, rows)
+	}
+
+	fmt.Fprintf(w, "\n## In sheet but not in guild (%d)\n\n", len(report.SheetPlayersNotInGuild))
+	rows = nil
+	for _, sheetName := range report.SheetPlayersNotInGuild {
+		row := []string{name(sheetName)}
+		if report.DidYouMean != nil {
+			row = append(row, name(report.DidYouMean[sheetName]))
+		}
+		rows = append(rows, row)
+	}
+	if report.DidYouMean != nil {
+		writeMarkdownTable(w, []string{"Sheet name", "Did you mean"}, rows)
+	} else {
+		writeMarkdownTable(w, []string{"Sheet name"}, rows)
+	}
+
+	fmt.Fprintf(w, "\n## Matches (%d)\n\n", len(report.Matches))
+	rows = nil
+	hasLoadouts := false
+	for _, match := range report.Matches {
+		hasLoadouts = hasLoadouts || match.Loadout != ""
+	}
+	for _, match := range report.Matches {
+		row := []string{name(match.GuildName), match.MatchType, match.MatchedName}
+		if hasLoadouts {
+			row = append(row, match.Loadout)
+		}
+		rows = append(rows, row)
+	}
+	if hasLoadouts {
+		writeMarkdownTable(w, []string{"Player", "Match type", "Sheet name", "Loadout"}, rows)
+	} else {
+		writeMarkdownTable(w, []string{"Player", "Match type", "Sheet name"}, rows)
+	}
+
+	if opts.RoleBaseline {
+		fmt.Fprintf(w, "\n## Role changes since baseline (%d)\n\n", len(report.RoleChanges))
+		rows = nil
+		for _, change := range report.RoleChanges {
+			rows = append(rows, []string{name(change.Username), change.OldRoles, change.NewRoles})
+		}
+		writeMarkdownTable(w, []string{"Player", "Old roles", "New roles"}, rows)
+	}
+
+	if opts.AbsentSince > 0 {
+		fmt.Fprintf(w, "\n## Stale online status (%d)\n\n", len(report.StaleOnline))
+		rows = nil
+		for _, player := range report.StaleOnline {
+			rows = append(rows, []string{name(player.Username), player.LastOnline.Format(signup.DefaultLastOnlineLayout)})
+		}
+		writeMarkdownTable(w, []string{"Player", "Last online"}, rows)
+	}
+
+	if opts.RequiredRole != "" {
+		fmt.Fprintf(w, "\n## Online players missing role %s (%d)\n\n", markdownCell(opts.RequiredRole), len(report.MissingRequiredRole))
+		rows = nil
+		for _, username := range report.MissingRequiredRole {
+			rows = append(rows, []string{name(username)})
+		}
+		writeMarkdownTable(w, []string{"Player"}, rows)
+	}
+
+	fmt.Fprintf(w, "\n## Summary\n\n")
+	summary := [][]string{
+		{"Total guild members", fmt.Sprint(report.Summary.TotalMembers)},
+		{"Online guild members", fmt.Sprint(report.Summary.OnlineMembers)},
+		{"Players in sheet", fmt.Sprint(report.Summary.SheetPlayers)},
+		{"Successful matches", fmt.Sprint(report.Summary.SuccessfulMatches)},
+		{"Online players missing from sheet", fmt.Sprint(report.Summary.Missing)},
+		{"Excluded players", fmt.Sprint(report.Summary.Excluded)},
+		{"Sheet players not in guild", fmt.Sprint(report.Summary.SheetNotInGuild)},
+		{"Signup compliance", report.Summary.Compliance()},
+	}
+	if report.Summary.Expected > 0 {
+		summary = append(summary, []string{"Expected signups", fmt.Sprint(report.Summary.Expected)})
+	}
+	if report.Summary.GuildLines != nil {
+		summary = append(summary, []string{"Guild file", report.Summary.GuildLines.String()})
+	}
+	writeMarkdownTable(w, []string{"Metric", "Count"}, summary)
+}
+
+// discordMessageLimit is the most characters Discord accepts in one webhook message
+const discordMessageLimit = 2000
+
+// discordMessages formats the missing players and counts for a Discord channel, split into
+// messages under discordMessageLimit at line boundaries so no name is cut in half
+func discordMessages(report Report, opts ReportOptions) []string {
+	lines := []string{fmt.Sprintf("**%d of %d online players are missing from the sheet** (%d in sheet but not in guild)",
+		report.Summary.Missing, report.Summary.OnlineMembers, report.Summary.SheetNotInGuild)}
+	for _, player := range report.MissingPlayers {
+		lines = append(lines, "- "+mentionName(player.Username, opts))
+	}
+
+	var messages []string
+	var current strings.Builder
+	currentLen := 0 // in runes, which is how Discord counts
+	for _, line := range lines {
+		lineLen := utf8.RuneCountInString(line)
+		if lineLen > discordMessageLimit {
+			line, lineLen = string([]rune(line)[:discordMessageLimit]), discordMessageLimit
+		}
+		if currentLen > 0 && currentLen+1+lineLen > discordMessageLimit {
+			messages = append(messages, current.String())
+			current.Reset()
+			currentLen = 0
+		}
+		if currentLen > 0 {
+			current.WriteString("\n")
+			currentLen++
+		}
+		current.WriteString(line)
+		currentLen += lineLen
+	}
+	if current.Len() > 0 {
+		messages = append(messages, current.String())
+	}
+	return messages
+}
+
+// postDiscordWebhook posts one message to a Discord webhook URL
+func postDiscordWebhook(client *http.Client, url string, content string) error {
+	body, err := json.Marshal(map[string]string{"content": content})
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to post to Discord webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("Discord webhook returned %s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+// reportServer answers -serve requests with a report from a fresh analysis of the data files,
+// so a dashboard always shows the current sheet
+type reportServer struct {
+	mu      sync.Mutex // analyses run one at a time, a slow fuzzy run shouldn't pile up
+	refresh time.Duration
+	analyze func(ctx context.Context) (Report, ReportOptions, error) // stops when the request's context is done
+}
+
+// handler routes the report at / and a health check at /healthz
+func (server *reportServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/", server.serveReport)
+	return mux
+}
+
+// serveReport runs the analysis and writes the report as JSON or as an HTML page, whichever
+// the Accept header prefers; browsers get HTML
+func (server *reportServer) serveReport(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	server.mu.Lock()
+	report, opts, err := server.analyze(r.Context())
+	server.mu.Unlock()
+	if err != nil {
+		log.Printf("Error: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	accept := r.Header.Get("Accept")
+	if acceptQuality(accept, "application/json") > acceptQuality(accept, "text/html") {
+		w.Header().Set("Content-Type", "application/json")
+		if err := writeReport(w, "json", report, opts); err != nil {
+			log.Printf("Error: %v", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	writeHTMLReport(w, report, opts, server.refresh)
+}
+
+// acceptQuality returns the q-value an Accept header gives a media type, taken from the most
+// specific range covering it, or 0 if none does
+func acceptQuality(accept string, mediaType string) float64 {
+	group, _, _ := strings.Cut(mediaType, "/")
+	quality, specificity := 0.0, -1
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		level := -1
+		switch strings.ToLower(strings.TrimSpace(params[0])) {
+		case mediaType:
+			level = 2
+		case group + "/*":
+			level = 1
+		case "*/*":
+			level = 0
+		}
+		if level <= specificity {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range params[1:] {
+			name, value, found := strings.Cut(param, "=")
+			if found && strings.TrimSpace(name) == "q" {
+				if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		quality, specificity = q, level
+	}
+	return quality
+}
+
+// writeHTMLReport writes the text report as a page that reloads itself every refresh, if set
+func writeHTMLReport(w io.Writer, report Report, opts ReportOptions, refresh time.Duration) {
+	var text strings.Builder
+	writeTextReport(&text, report, opts)
+
+	fmt.Fprintln(w, "<!DOCTYPE html>")
+	fmt.Fprintln(w, "<html>\n<head>\n<meta charset=\"utf-8\">")
+	if refresh > 0 {
+		fmt.Fprintf(w, "<meta http-equiv=\"refresh\" content=\"%d\">\n", max(int(refresh.Seconds()), 1))
+	}
+	fmt.Fprintf(w, "<title>Signup check: %d missing</title>\n</head>\n<body>\n", report.Summary.Missing)
+	fmt.Fprintf(w, "<pre>%s</pre>\n", html.EscapeString(text.String()))
+	fmt.Fprintln(w, "</body>\n</html>")
+}
+
+// loadoutNote returns the loadout shown after a matched player, like " [Longbow]", if any
+func loadoutNote(match signup.MatchResult) string {
+	if match.Loadout == "" {
+		return ""
+	}
+	return " [" + match.Loadout + "]"
+}
+
+// strippedNote describes what -strip-tags and -strip-numbers removed from the matched sheet
+// name, like "tag '[KOS]' and suffix '_S9'", or returns "" if nothing was
+func strippedNote(match signup.MatchResult) string {
+	var removed []string
+	if match.StrippedTag != "" {
+		removed = append(removed, fmt.Sprintf("tag '%s'", match.StrippedTag))
+	}
+	if match.StrippedSuffix != "" {
+		removed = append(removed, fmt.Sprintf("suffix '%s'", match.StrippedSuffix))
+	}
+	return strings.Join(removed, " and ")
+}
+
+// writeTextReport writes the human-readable report
+func writeTextReport(w io.Writer, report Report, opts ReportOptions) {
+	missingPlayers, excludedPlayers, guildMatches := report.MissingPlayers, report.ExcludedPlayers, report.Matches
+	sheetPlayersNotInGuild := report.SheetPlayersNotInGuild
+
+	if report.Run != nil {
+		fmt.Fprintf(w, "Run %s at %s\n", report.Run.ID, report.Run.Timestamp)
+	}
+
+	// Show successful matches first
+	if len(guildMatches) > 0 {
+		fmt.Fprintf(w, "\n=== SUCCESSFUL MATCHES ===\n")
+		directMatches := 0
+		alternativeMatches := 0
+		ignoredMatches := 0
+		tokenMatches := 0
+		fuzzyMatches := 0
+		phoneticMatches := 0
+
+		// List the individual matches alphabetically so they are easy to scan
+		sortedMatches := make([]signup.MatchResult, len(guildMatches))
+		copy(sortedMatches, guildMatches)
+		sort.SliceStable(sortedMatches, func(i, j int) bool {
+			return strings.ToLower(sortedMatches[i].GuildName) < strings.ToLower(sortedMatches[j].GuildName)
+		})
+
+		for _, match := range sortedMatches {
+			switch match.MatchType {
+			case "direct":
+				if match.WhitespaceNormalized {
+					fmt.Fprintf(w, "Matched: %s (found as '%s' in sheet, ignoring spaces and underscores)%s\n", displayName(match.GuildName, opts.TitleCase), match.MatchedName, loadoutNote(match))
+				} else if removed := strippedNote(match); removed != "" {
+					fmt.Fprintf(w, "Matched: %s (found after removing %s in sheet)%s\n", displayName(match.GuildName, opts.TitleCase), removed, loadoutNote(match))
+				}
+				directMatches++
+			case "alternative":
+				if match.FormerName {
+					fmt.Fprintf(w, "Matched: %s (matched via former name '%s' in sheet)%s\n", displayName(match.GuildName, opts.TitleCase), match.AlternativeName, loadoutNote(match))
+				} else {
+					fmt.Fprintf(w, "Matched: %s (found as '%s' in sheet)%s\n", displayName(match.GuildName, opts.TitleCase), match.AlternativeName, loadoutNote(match))
+				}
+				alternativeMatches++
+			case "ignored":
+				fmt.Fprintf(w, "Matched: %s (pattern match with '%s' in sheet)%s\n", displayName(match.GuildName, opts.TitleCase), match.AlternativeName, loadoutNote(match))
+				ignoredMatches++
+			case "token":
+				fmt.Fprintf(w, "Matched: %s (word of '%s' in sheet)%s\n", displayName(match.GuildName, opts.TitleCase), match.AlternativeName, loadoutNote(match))
+				tokenMatches++
+			case "fuzzy":
+				if match.Similarity > 0 {
+					fmt.Fprintf(w, "Matched: %s (close to '%s' in sheet, similarity %.2f)%s\n", displayName(match.GuildName, opts.TitleCase), match.AlternativeName, match.Similarity, loadoutNote(match))
+				} else {
+					fmt.Fprintf(w, "Matched: %s (close to '%s' in sheet, distance %d)%s\n", displayName(match.GuildName, opts.TitleCase), match.AlternativeName, match.Distance, loadoutNote(match))
+				}
+				fuzzyMatches++
+			case "phonetic":
+				fmt.Fprintf(w, "Matched: %s (sounds like '%s' in sheet)%s\n", displayName(match.GuildName, opts.TitleCase), match.AlternativeName, loadoutNote(match))
+				phoneticMatches++
+			}
+		}
+
+		fmt.Fprintf(w, "- Direct matches: %d\n", directMatches)
+		fmt.Fprintf(w, "- Alternative name matches: %d\n", alternativeMatches)
+		if ignoredMatches > 0 {
+			fmt.Fprintf(w, "- Pattern matches: %d\n", ignoredMatches)
+		}
+		if tokenMatches > 0 {
+			fmt.Fprintf(w, "- Token matches: %d\n", tokenMatches)
+		}
+		if fuzzyMatches > 0 {
+			fmt.Fprintf(w, "- Fuzzy matches: %d\n", fuzzyMatches)
+		}
+		if phoneticMatches > 0 {
+			fmt.Fprintf(w, "- Phonetic matches: %d\n", phoneticMatches)
+		}
+	}
+
+	// Output results
+	fmt.Fprintf(w, "\n=== RESULTS ===\n")
+	fmt.Fprintf(w, "Players online but not in sheet (%d):\n", len(missingPlayers))
+
+	if len(missingPlayers) == 0 {
+		fmt.Fprintln(w, "  (none)")
+	} else {
+		for i, player := range missingPlayers {
+			name := mentionName(player.Username, opts)
+			if i == len(missingPlayers)-1 {
+				fmt.Fprintf(w, "  %s\n", name)
+			} else {
+				fmt.Fprintf(w, "  %s,\n", name)
+			}
+		}
+	}
+
+	// Confirm who is present, not just who is missing
+	if opts.ShowMatched {
+		sortedMatches := make([]signup.MatchResult, len(guildMatches))
+		copy(sortedMatches, guildMatches)
+		sort.SliceStable(sortedMatches, func(i, j int) bool {
+			return strings.ToLower(sortedMatches[i].GuildName) < strings.ToLower(sortedMatches[j].GuildName)
+		})
+
+		fmt.Fprintf(w, "\nPlayers in guild and sheet (%d):\n", len(sortedMatches))
+		if len(sortedMatches) == 0 {
+			fmt.Fprintln(w, "  (none)")
+		}
+		for _, match := range sortedMatches {
+			fmt.Fprintf(w, "  %s (%s)%s\n", displayName(match.GuildName, opts.TitleCase), match.MatchType, loadoutNote(match))
+		}
+	}
+
+	// Show which roles the missing players would have filled
+	if opts.GroupByRole && len(report.MissingByRole) > 0 {
+		fmt.Fprintf(w, "\nMissing players by role:\n")
+		for _, group := range report.MissingByRole {
+			role := group.Role
+			if role == "" {
+				role = "(no role)"
+			}
+			fmt.Fprintf(w, "  %s (%d):\n", role, len(group.Players))
+			for _, player := range group.Players {
+				fmt.Fprintf(w, "    %s\n", displayName(player.Username, opts.TitleCase))
+			}
+		}
+	}
+
+	// Show excluded players
+	if len(excludedPlayers) > 0 {
+		if opts.ExcludeNoRole {
+			fmt.Fprintf(w, "\nExcluded players (have special roles or no role) (%d):\n", len(excludedPlayers))
+		} else {
+			fmt.Fprintf(w, "\nExcluded players (have special roles) (%d):\n", len(excludedPlayers))
+		}
+		for i, player := range excludedPlayers {
+			name := displayName(player.Username, opts.TitleCase)
+			if player.Reason != "special role" {
+				name = fmt.Sprintf("%s (%s)", name, player.Reason)
+			}
+			if i == len(excludedPlayers)-1 {
+				fmt.Fprintf(w, "  %s\n", name)
+			} else {
+				fmt.Fprintf(w, "  %s,\n", name)
+			}
+		}
+	}
+
+	// Show players in sheet but not in guild
+	if len(sheetPlayersNotInGuild) > 0 {
+		fmt.Fprintf(w, "\nPlayers in sheet but not in guild (%d):\n", len(sheetPlayersNotInGuild))
+		for i, player := range sheetPlayersNotInGuild {
+			name := displayName(player, opts.TitleCase)
+			if suggestion, found := report.DidYouMean[player]; found {
+				name = fmt.Sprintf("\"%s\" (did you mean \"%s\"?)", name, displayName(suggestion, opts.TitleCase))
+			}
+			if i == len(sheetPlayersNotInGuild)-1 {
+				fmt.Fprintf(w, "  %s\n", name)
+			} else {
+				fmt.Fprintf(w, "  %s,\n", name)
+			}
+		}
+	}
+
+	// Show promotions and demotions since the role baseline
+	if opts.RoleBaseline {
+		fmt.Fprintf(w, "\nRole changes since baseline (%d):\n", len(report.RoleChanges))
+		if len(report.RoleChanges) == 0 {
+			fmt.Fprintln(w, "  (none)")
+		}
+		for _, change := range report.RoleChanges {
+			fmt.Fprintf(w, "  %s: '%s' -> '%s'\n", displayName(change.Username, opts.TitleCase), change.OldRoles, change.NewRoles)
+		}
+	}
+
+	// Show members marked online whose last online time says otherwise
+	if opts.AbsentSince > 0 {
+		fmt.Fprintf(w, "\nStale online status, last online over %s ago (%d):\n", opts.AbsentSince, len(report.StaleOnline))
+		if len(report.StaleOnline) == 0 {
+			fmt.Fprintln(w, "  (none)")
+		}
+		for _, player := range report.StaleOnline {
+			fmt.Fprintf(w, "  %s (last online %s)\n", displayName(player.Username, opts.TitleCase), player.LastOnline.Format(signup.DefaultLastOnlineLayout))
+		}
+	}
+
+	// Show online members without the role everyone is required to have
+	if opts.RequiredRole != "" {
+		fmt.Fprintf(w, "\nOnline players missing required role '%s' (%d):\n", opts.RequiredRole, len(report.MissingRequiredRole))
+		if len(report.MissingRequiredRole) == 0 {
+			fmt.Fprintln(w, "  (none)")
+		}
+		for _, name := range report.MissingRequiredRole {
+			fmt.Fprintf(w, "  %s\n", displayName(name, opts.TitleCase))
+		}
+	}
+
+	// Show everything that needs attention in one list, most urgent first
+	if opts.BySeverity {
+		discrepancies := signup.ClassifyDiscrepancies(missingPlayers, sheetPlayersNotInGuild, opts.RoleWeights)
+		if len(discrepancies) > 0 {
+			fmt.Fprintf(w, "\nDiscrepancies by severity (%d):\n", len(discrepancies))
+			for _, discrepancy := range discrepancies {
+				fmt.Fprintf(w, "  [%s] %s - %s\n", discrepancy.Severity, displayName(discrepancy.Name, opts.TitleCase), discrepancy.Problem)
+			}
+		}
+	}
+
+	// Group the missing players by the likely reason, to show whether typos, aliases or no-shows dominate
+	if opts.MissReasons {
+		fmt.Fprintf(w, "\nWhy players are missing (%d):\n", len(report.MissReasons))
+		if len(report.MissReasons) == 0 {
+			fmt.Fprintln(w, "  (none)")
+		}
+		for _, reason := range signup.MissReasonOrder {
+			var misses []signup.MissReason
+			for _, miss := range report.MissReasons {
+				if miss.Reason == reason {
+					misses = append(misses, miss)
+				}
+			}
+			if len(misses) == 0 {
+				continue
+			}
+			fmt.Fprintf(w, "  %s (%d):\n", reason, len(misses))
+			for _, miss := range misses {
+				name := displayName(miss.Name, opts.TitleCase)
+				if reason == "likely no-show" {
+					fmt.Fprintf(w, "    %s\n", name)
+				} else {
+					fmt.Fprintf(w, "    %s - '%s' in sheet (distance %d)\n", name, miss.Closest, miss.Distance)
+				}
+			}
+		}
+	}
+
+	// Pair up the two discrepancy lists, as the same person is often listed in both under different names
+	if opts.SuggestAliases {
+		suggestions := signup.FindAliasSuggestions(signup.Usernames(missingPlayers), sheetPlayersNotInGuild)
+		if len(suggestions) > 0 {
+			fmt.Fprintf(w, "\nPossible alternative names, add to sheet-names.txt if correct (%d):\n", len(suggestions))
+			for _, suggestion := range suggestions {
+				fmt.Fprintf(w, "  %s:%s (distance %d)\n", suggestion.GuildName, suggestion.SheetName, suggestion.Distance)
+			}
+		}
+	}
+
+	// Show sheet matches if any
+	/*
+		if len(sheetMatches) > 0 {
+			fmt.Fprintf(w, "\nSheet name matches found (%d):\n", len(sheetMatches))
+			for _, match := range sheetMatches {
+				if match.MatchType == "alternative" {
+					fmt.Fprintf(w, "  '%s' in sheet -> %s in guild\n", match.AlternativeName, match.GuildName)
+				}
+			}
+		}
+	*/
+
+	fmt.Fprintf(w, "\nSummary:\n")
+	fmt.Fprintf(w, "- Total guild members: %d\n", report.Summary.TotalMembers)
+	if report.Summary.GuildLines != nil {
+		fmt.Fprintf(w, "- Guild file: %s\n", report.Summary.GuildLines)
+	}
+	fmt.Fprintf(w, "- Online guild members: %d\n", report.Summary.OnlineMembers)
+	fmt.Fprintf(w, "- Players in sheet: %d\n", report.Summary.SheetPlayers)
+	fmt.Fprintf(w, "- Successful matches: %d\n", report.Summary.SuccessfulMatches)
+	fmt.Fprintf(w, "- Online players missing from sheet: %d\n", len(missingPlayers))
+	if opts.ExcludeNoRole {
+		fmt.Fprintf(w, "- Excluded players (special roles or no role): %d\n", len(excludedPlayers))
+	} else {
+		fmt.Fprintf(w, "- Excluded players (special roles): %d\n", len(excludedPlayers))
+	}
+	fmt.Fprintf(w, "- Sheet players not in guild: %d\n", len(sheetPlayersNotInGuild))
+	fmt.Fprintf(w, "- Signup compliance: %s\n", report.Summary.Compliance())
+
+	// Compare the online players found in the sheet against the target
+	if expected := report.Summary.Expected; expected > 0 {
+		present := len(guildMatches)
+		switch {
+		case present > expected:
+			fmt.Fprintf(w, "- Expected signups: %d, exceeded by %d (%d present)\n", expected, present-expected, present)
+		case present < expected:
+			fmt.Fprintf(w, "- Expected signups: %d, fell short by %d (%d present)\n", expected, expected-present, present)
+		default:
+			fmt.Fprintf(w, "- Expected signups: %d, met exactly\n", expected)
+		}
+	}
+}
+
+func main() {
+	guildFile := flag.String("guild", "data/guild.txt", "path of the guild member export, or - to read it from stdin")
+	sheetFile := flag.String("sheet", "data/sheet.txt", "path of the signup sheet; a comma-separated list or glob merges several sheets")
+	gsheetID := flag.String("gsheet", "", "read the sheet from this Google Sheets spreadsheet ID instead of -sheet, as a service account")
+	gsheetRange := flag.String("gsheet-range", "A:A", "with -gsheet, the range to read in A1 notation like Roster!B2:B; every non-empty cell is a sheet entry")
+	gsheetCredentials := flag.String("gsheet-credentials", "", "with -gsheet, the service account key file (default $GOOGLE_APPLICATION_CREDENTIALS)")
+	gsheetCache := flag.String("gsheet-cache", "data/gsheet-cache.txt", "with -gsheet, where the last fetched copy is kept; it is used when the API can't be reached")
+	altNamesFile := flag.String("altnames", "data/sheet-names.txt", "path of the alternative names file")
+	namesOnly := flag.Bool("names-only", false, "print only the names of online players missing from the sheet, one per line")
+	onlyMissing := flag.Bool("only-missing", false, "like -names-only, but with the progress messages on stderr; takes precedence over -format")
+	validate := flag.Bool("validate", false, "check the input files for likely mistakes and exit")
+	fuzzyDistance := flag.Int("fuzzy", 0, "allow fuzzy matches within this many edits, whatever the name length; overrides -fuzzy-ratio (0 disables)")
+	fuzzyAlgo := flag.String("fuzzy-algo", "levenshtein", "fuzzy matching algorithm: levenshtein or damerau (limited by -fuzzy or -fuzzy-ratio), jaro-winkler or trigram (limited by -fuzzy-threshold), or metaphone for names that sound alike")
+	fuzzyThreshold := flag.Float64("fuzzy-threshold", 0.9, "lowest similarity, from 0 to 1, that counts as a match with -fuzzy-algo jaro-winkler or trigram")
+	fuzzyRatio := flag.Float64("fuzzy-ratio", 0, "allow fuzzy matches within floor(name length * ratio) edits, rounded down so short names like Bo must match exactly (0 disables fuzzy matching)")
+	listAltNames := flag.Bool("list-altnames", false, "print each guild member's alternative names, alphabetically, and exit; with -v also members without any")
+	dumpGuild := flag.Bool("dump-guild", false, "print the parsed guild members as JSON and exit")
+	maxAge := flag.Duration("max-age", 0, "refuse to run if the guild file is older than this, e.g. 10m (0 disables the check)")
+	verbose := flag.Bool("v", false, "log details about how names were handled; same as -log-level debug")
+	logLevel := flag.String("log-level", "info", "info logs warnings only; debug also logs how names were handled and every match attempt")
+	logPrefix := flag.String("log-prefix", "", "prefix for every log line, e.g. \"[signup] \"")
+	suggestAliases := flag.Bool("suggest-aliases", false, "suggest alternative names for missing players that resemble unmatched sheet names")
+	stripPrefixChars := flag.Int("strip-prefix-chars", 0, "remove this many leading characters from each sheet name")
+	stripSuffixChars := flag.Int("strip-suffix-chars", 0, "remove this many trailing characters from each sheet name")
+	expected := flag.Int("expected", 0, "number of signups expected; the summary reports whether it was met (0 disables)")
+	rolesFile := flag.String("roles-file", "", "merge roles from a file of Username:Role1;Role2 lines into the guild data")
+	excludeNoRole := flag.Bool("exclude-no-role", false, "exclude online players without any role (recruits) instead of listing them as missing")
+	bySeverity := flag.Bool("by-severity", false, "also list all discrepancies together, most urgent first")
+	delimiter := flag.String("delimiter", "auto", "guild file field separator: tab, comma, any single character, or auto to detect tab or comma from the header")
+	roleDelimiter := flag.String("role-delimiter", ";", "separator between roles in the guild file's roles column, e.g. \",\" or \"|\"")
+	onlyRoles := flag.String("only-roles", "", "comma-separated roles, case-insensitive; only online players with at least one of them are checked, e.g. \"Raider\"")
+	onlineStatus := flag.String("online-status", "Online", "comma-separated guild statuses counted as online, case-insensitive, e.g. \"Online,Active\"")
+	lastOnlineLayout := flag.String("last-online-layout", signup.DefaultLastOnlineLayout, "Go time layout of the guild file's optional fourth column, the last online time")
+	headerLines := flag.Int("header-lines", 1, "number of leading guild file lines to skip as a header, e.g. 2 for a title and the column names, 0 for none")
+	strict := flag.Bool("strict", false, "fail on a malformed guild line instead of warning and skipping it")
+	skipFooter := flag.Bool("skip-footer", false, "silently skip a malformed last line in the guild file, such as a totals footer")
+	nameInParens := flag.Bool("name-in-parens", false, "take each sheet name from its first parentheses, for sheets like \"Nickname (RealIGN)\"")
+	quiet := flag.Bool("quiet", false, "suppress progress messages and the final DONE line")
+	disableCleaners := flag.String("disable-cleaners", "", "comma-separated sheet name cleaners to turn off: list-number, parens, mention, discriminator")
+	compareOld := flag.String("compare", "", "list the members who joined and left since this older guild export and exit")
+	compareRostersMode := flag.Bool("compare-rosters", false, "compare the guild file against the -guild2 file and exit")
+	guild2 := flag.String("guild2", "", "second guild file for -compare-rosters")
+	runID := flag.String("run-id", "", "identifier printed with a UTC timestamp at the top of the report; \"auto\" generates a UUID")
+	placeholders := flag.String("placeholders", strings.Join(signup.DefaultPlaceholders(), ","), "comma-separated sheet entries to drop as placeholders (exact, case-insensitive)")
+	keepDuplicates := flag.Bool("keep-duplicates", false, "keep sheet names listed more than once instead of counting them once")
+	dedupeReport := flag.Bool("dedupe-report", false, "list names that only become equal after normalization, and the step responsible, then exit")
+	check := flag.String("check", "", "check whether a single guild or sheet name is matched, exiting 0 if it is and 1 if not")
+	tokenMatch := flag.Bool("token-match", false, "match sheet names that contain the guild name as a word, e.g. \"Xandor the Brave\" as Xandor")
+	tokenOverlap := flag.Float64("token-overlap", 1, "with -token-match, the fraction of a guild name's words the sheet name must contain")
+	caseSensitive := flag.Bool("case-sensitive", false, "match names and alternative names only with the same case, for guilds where XANDOR and Xandor are different accounts")
+	workers := flag.Int("workers", runtime.NumCPU(), "number of names matched at once; 1 matches one at a time")
+	normalizeWhitespace := flag.Bool("normalize-whitespace", false, "match names that only differ in spaces and underscores, e.g. \"Xan dor\" or \"Xan_dor\" as Xandor")
+	stripTags := flag.Bool("strip-tags", false, "remove a leading clan tag like \"[KOS] \" from sheet names")
+	stripSymbols := flag.Bool("strip-symbols", false, "remove emoji and other symbols from sheet names, keeping only letters, digits and spaces")
+	stripNumbers := flag.Bool("strip-numbers", false, "remove trailing season/server numbers behind a separator from sheet names, e.g. \"Player_S9\"")
+	roleBaseline := flag.String("role-baseline", "", "report members whose roles changed since this baseline file")
+	writeBaseline := flag.Bool("write-role-baseline", false, "write the current roles to the -role-baseline file and exit")
+	summaryCSV := flag.Bool("summary-csv", false, "print only one CSV line: timestamp,online,present,missing,excluded,sheet_not_in_guild")
+	summaryCSVHeader := flag.Bool("summary-csv-header", false, "print the column names before the -summary-csv line")
+	minRosterFraction := flag.Float64("min-roster-fraction", 0, "warn when the guild has more than this fraction fewer members than the average of recent runs, e.g. 0.2 (0 disables)")
+	deltaOnly := flag.Bool("delta-only", false, "print only the players who became or stopped being missing or unknown since the previous -delta-only run; the first run prints the full report")
+	deltaFile := flag.String("delta-file", "data/last-run.json", "file keeping the previous run's missing and unknown names for -delta-only")
+	rosterHistory := flag.String("roster-history", "data/roster-history.txt", "file keeping the member counts of recent runs for -min-roster-fraction")
+	format := flag.String("format", "text", "report format on stdout: text, json, csv or markdown")
+	xlsxFile := flag.String("xlsx", "", "also write the report to this Excel workbook, with Summary, Missing, Excluded, Not in Guild and Matched sheets")
+	var sinks outputSinks
+	flag.Var(&sinks, "out", "write the report to this file instead of stdout, as PATH or PATH:FORMAT with FORMAT text, json, csv or markdown (default -format); a PATH of - is stdout, to keep the report there next to files; repeatable")
+	suggest := flag.Bool("suggest", false, "show the closest guild name next to sheet names that look like misspellings")
+	explainMisses := flag.Bool("miss-reasons", false, "group missing players by likely reason: typo, missing alias or no-show")
+	failOnMissing := flag.Bool("fail-on-missing", false, "exit with code 1 when online players are missing from the sheet")
+	minMissing := flag.Int("min-missing", 1, "with -fail-on-missing, only fail when at least this many players are missing")
+	titleCaseOutput := flag.Bool("title-case-output", false, "show names in the text report in title case, e.g. \"xSarge\" as \"Xsarge\" (matching is unaffected)")
+	roleRequired := flag.String("role-required", "", "list online members who lack this role, e.g. \"Verified\", in a separate section")
+	serveAddr := flag.String("serve", "", "serve the report over HTTP on this address, e.g. :8080, analyzing the files again for every request")
+	serveRefresh := flag.Duration("serve-refresh", time.Minute, "with -serve, how often the HTML page reloads itself (0 disables)")
+	mention := flag.Bool("mention", false, "show missing players as Discord mentions, using the user IDs in data/discord-ids.txt")
+	discordWebhook := flag.String("discord-webhook", "", "post the online players missing from the sheet to this Discord webhook URL")
+	templateFile := flag.String("template", "", "print the report through this Go text/template file instead of -format; \"default\" uses the built-in template matching the text report")
+	showMatched := flag.Bool("show-matched", false, "list every online player found in both the guild and the sheet, with how they matched")
+	sortOrder := flag.String("sort", "input", "order of the missing, excluded and not-in-guild lists: input (file order), name or role")
+	groupByRole := flag.Bool("group-by-role", false, "also list the missing players grouped by their first role")
+	groupAllRoles := flag.Bool("group-all-roles", false, "with -group-by-role, list players with several roles under each of them instead of the first")
+	absentSince := flag.Duration("absent-since", 0, "list online members whose last online time is older than this, e.g. 2h, as stale (0 disables)")
+	strictAltNames := flag.Bool("strict-altnames", false, "fail when the alternative names file maps names that aren't guild members or has conflicting alternative names")
+	combined := flag.String("combined", "", "read guild, sheet and alias data from one file with [guild], [sheet] and [aliases] sections")
+	configFile := flag.String("config", "", "read flag values from this file of \"flag-name: value\" lines; flags on the command line override it")
+	flag.Parse()
+
+	// Settings from the config file apply before anything reads the flags
+	if *configFile != "" {
+		if err := applyConfigFile(*configFile); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+	}
+
+	log.SetPrefix(*logPrefix)
+	signup.DebugLog.SetPrefix(*logPrefix)
+	switch *logLevel {
+	case "info":
+	case "debug":
+		*verbose = true
+	default:
+		log.Fatalf("Error: unknown -log-level %q, expected info or debug", *logLevel)
+	}
+	if *verbose {
+		signup.DebugLog.SetOutput(os.Stderr)
+	}
+
+	if *namesOnly || *dumpGuild || *listAltNames || *quiet || *check != "" || *summaryCSV {
+		progress = io.Discard
+	}
+	if *deltaOnly && progress != io.Discard {
+		// A live display shows only the changes
+		progress = os.Stderr
+	}
+
+	// Only the missing names go to stdout, so "-only-missing | wc -l" counts them
+	if *onlyMissing {
+		*namesOnly = true
+		if !*quiet {
+			progress = os.Stderr
+		}
+	}
+
+	// Machine-readable output keeps stdout for the report alone
+	switch *format {
+	case "text":
+	case "json", "csv", "markdown":
+		if progress != io.Discard {
+			progress = os.Stderr
+		}
+	default:
+		log.Fatalf("Error: unknown -format %q, expected one of %s", *format, strings.Join(outputFormats, ", "))
+	}
+	switch *sortOrder {
+	case "input", "name", "role":
+	default:
+		log.Fatalf("Error: unknown -sort %q, expected one of %s", *sortOrder, strings.Join(signup.SortOrders, ", "))
+	}
+
+	// A Google Sheet is fetched into its cache file, which is then read as the sheet
+	var gsheet googleSheet
+	if *gsheetID != "" {
+		if *combined != "" {
+			log.Fatalf("Error: -gsheet and -combined both give the sheet, use only one")
+		}
+		credentials := *gsheetCredentials
+		if credentials == "" {
+			credentials = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+		}
+		if credentials == "" {
+			log.Fatalf("Error: -gsheet needs a service account key file from -gsheet-credentials or GOOGLE_APPLICATION_CREDENTIALS")
+		}
+		gsheet = googleSheet{SpreadsheetID: *gsheetID, Range: *gsheetRange, Credentials: credentials, Cache: *gsheetCache}
+		*sheetFile = *gsheetCache
+	}
+	googleClient := &http.Client{Timeout: 30 * time.Second}
+
+	// Output files take the report instead of stdout, in -format unless a sink names its own
+	for i := range sinks {
+		if sinks[i].Format == "" {
+			sinks[i].Format = *format
+		}
+	}
+	if len(sinks) > 0 && progress != io.Discard {
+		progress = os.Stderr
+	}
+
+	// A report template is loaded up front so a broken one fails before the inputs are read
+	var reportTemplate *template.Template
+	if *templateFile != "" {
+		var err error
+		reportTemplate, err = loadReportTemplate(*templateFile, *titleCaseOutput)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		if progress != io.Discard {
+			progress = os.Stderr
+		}
+	}
+
+	// Ctrl-C or SIGTERM cancels ctx: reading and matching stop, and the run ends without output
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Inputs are read through these so the pipeline can also run against in-memory fixtures
+	var fsys fs.FS = contextFS{fsys: osFS{}, ctx: ctx}
+	var clock Clock = systemClock{}
+
+	guildOpts := signup.GuildOptions{
+		SkipFooter:       *skipFooter,
+		RoleDelimiter:    *roleDelimiter,
+		LastOnlineLayout: *lastOnlineLayout,
+		HeaderLines:      max(*headerLines, 0),
+		Strict:           *strict,
+		Stats:            &signup.GuildParseStats{},
+	}
+	switch *delimiter {
+	case "auto":
+	case "tab":
+		guildOpts.Delimiter = '\t'
+	case "comma":
+		guildOpts.Delimiter = ','
+	default:
+		runes := []rune(*delimiter)
+		if len(runes) != 1 || runes[0] == '"' || runes[0] == '\n' || runes[0] == '\r' {
+			log.Fatalf("Error: invalid -delimiter %q, expected tab, comma, auto or a single character", *delimiter)
+		}
+		guildOpts.Delimiter = runes[0]
+	}
+
+	cleanOpts := signup.CleanOptions{
+		StripPrefixChars: max(*stripPrefixChars, 0),
+		StripSuffixChars: max(*stripSuffixChars, 0),
+		NameInParens:     *nameInParens,
+		StripNumbers:     *stripNumbers,
+		StripSymbols:     *stripSymbols,
+		StripTags:        *stripTags,
+		StrippedTags:     make(map[string]string),
+		StrippedSuffixes: make(map[string]string),
+		Loadouts:         make(map[string]string),
+		DisabledCleaners: make(map[string]bool),
+		Placeholders:     make(map[string]bool),
+	}
+	for _, placeholder := range strings.Split(*placeholders, ",") {
+		if placeholder = strings.TrimSpace(placeholder); placeholder != "" {
+			cleanOpts.Placeholders[strings.ToLower(placeholder)] = true
+		}
+	}
+	for _, name := range strings.Split(*disableCleaners, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		known := false
+		for _, cleanerName := range signup.CleanerNames() {
+			known = known || cleanerName == name
+		}
+		if !known {
+			log.Printf("Warning: ignoring unknown cleaner %q in -disable-cleaners", name)
+			continue
+		}
+		cleanOpts.DisabledCleaners[name] = true
+	}
+
+	matchOpts := signup.MatchOptions{
+		FuzzyRatio:          *fuzzyRatio,
+		FuzzyDistance:       *fuzzyDistance,
+		ExcludeNoRole:       *excludeNoRole,
+		Now:                 clock.Now(),
+		SheetTags:           cleanOpts.StrippedTags,
+		SheetSuffixes:       cleanOpts.StrippedSuffixes,
+		SheetLoadouts:       cleanOpts.Loadouts,
+		NormalizeWhitespace: *normalizeWhitespace,
+		Workers:             *workers,
+		CaseSensitive:       *caseSensitive,
+	}
+	if *tokenMatch {
+		if *tokenOverlap <= 0 || *tokenOverlap > 1 {
+			log.Fatalf("Error: -token-overlap must be in (0,1], got %v", *tokenOverlap)
+		}
+		matchOpts.TokenOverlap = *tokenOverlap
+	}
+	// Levenshtein stays off unless -fuzzy or -fuzzy-ratio set a limit, while choosing another algorithm turns it on
+	if *fuzzyAlgo != "levenshtein" {
+		algorithm, err := signup.NewFuzzyAlgorithm(*fuzzyAlgo, *fuzzyRatio, *fuzzyDistance, *fuzzyThreshold)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		matchOpts.FuzzyAlgorithm = algorithm
+	}
+	for _, status := range strings.Split(*onlineStatus, ",") {
+		if status = strings.TrimSpace(status); status != "" {
+			matchOpts.OnlineStatuses = append(matchOpts.OnlineStatuses, status)
+		}
+	}
+	for _, role := range strings.Split(*onlyRoles, ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			matchOpts.OnlyRoles = append(matchOpts.OnlyRoles, role)
+		}
+	}
+
+	reportOpts := ReportOptions{
+		TitleCase:      *titleCaseOutput,
+		ExcludeNoRole:  *excludeNoRole,
+		RoleBaseline:   *roleBaseline != "",
+		RequiredRole:   *roleRequired,
+		BySeverity:     *bySeverity,
+		SuggestAliases: *suggestAliases,
+		MissReasons:    *explainMisses,
+		GroupByRole:    *groupByRole,
+		ShowMatched:    *showMatched,
+	}
+	if *bySeverity {
+		var err error
+		reportOpts.RoleWeights, err = signup.ParseRoleWeightsFile(fsys, "data/role-weights.txt")
+		if err != nil {
+			log.Fatalf("Error parsing role weights file: %v", err)
+		}
+	}
+	if *mention {
+		var err error
+		reportOpts.Mentions, err = signup.ParseDiscordIDsFile(fsys, "data/discord-ids.txt")
+		if err != nil {
+			log.Fatalf("Error parsing Discord IDs file: %v", err)
+		}
+	}
+	settings := reportSettings{
+		Expected:      *expected,
+		GroupAllRoles: *groupAllRoles,
+		Suggest:       *suggest,
+		AbsentSince:   *absentSince,
+		GuildStats:    guildOpts.Stats,
+		Sort:          *sortOrder,
+	}
+
+	// Serve mode repeats the analysis for every request instead of running it once
+	if *serveAddr != "" {
+		if *deltaOnly {
+			log.Fatalf("Error: -delta-only compares one run with the previous one, so it can't be used with -serve")
+		}
+		if *guildFile == "-" && *combined == "" {
+			log.Fatalf("Error: -serve reads the guild file again for every request, so it can't come from stdin")
+		}
+		server := &reportServer{refresh: *serveRefresh, analyze: func(ctx context.Context) (Report, ReportOptions, error) {
+			// A client that goes away, or shutting down, stops its analysis
+			fsys := contextFS{fsys: fsys, ctx: ctx}
+
+			// Parsing fills in the options, so every run starts from fresh copies
+			cleanOpts, matchOpts, reportOpts, settings, guildOpts := cleanOpts, matchOpts, reportOpts, settings, guildOpts
+			guildOpts.Stats = &signup.GuildParseStats{}
+			settings.GuildStats = guildOpts.Stats
+			cleanOpts.StrippedTags = make(map[string]string)
+			matchOpts.SheetTags = cleanOpts.StrippedTags
+			cleanOpts.StrippedSuffixes = make(map[string]string)
+			matchOpts.SheetSuffixes = cleanOpts.StrippedSuffixes
+			cleanOpts.Loadouts = make(map[string]string)
+			matchOpts.SheetLoadouts = cleanOpts.Loadouts
+			matchOpts.Now = clock.Now()
+
+			inputs, err := loadAnalysisInputs(fsys, *combined, *altNamesFile, &cleanOpts, &matchOpts, io.Discard)
+			if err != nil {
+				return Report{}, reportOpts, err
+			}
+			if *maxAge > 0 {
+				guildSource := *guildFile
+				if *combined != "" {
+					guildSource = *combined
+				}
+				if err := checkFileAge(fsys, clock, guildSource, *maxAge); err != nil {
+					return Report{}, reportOpts, err
+				}
+			}
+			guildPlayers, err := readGuildPlayers(ctx, fsys, inputs, *guildFile, guildOpts, *caseSensitive, io.Discard)
+			if err != nil {
+				return Report{}, reportOpts, err
+			}
+			if *rolesFile != "" {
+				roles, err := signup.ParseRolesFile(fsys, *rolesFile)
+				if err != nil {
+					return Report{}, reportOpts, fmt.Errorf("failed to parse roles file: %w", err)
+				}
+				signup.MergeRoles(guildPlayers, roles)
+			}
+			if *roleBaseline != "" {
+				settings.RoleBaseline, err = signup.ParseRolesFile(fsys, *roleBaseline)
+				if err != nil {
+					return Report{}, reportOpts, fmt.Errorf("failed to parse role baseline: %w", err)
+				}
+			}
+			if *gsheetID != "" {
+				if err := syncGoogleSheet(ctx, fsys, clock, googleClient, gsheet, io.Discard); err != nil {
+					return Report{}, reportOpts, err
+				}
+			}
+			sheetFiles, err := expandSheetFiles(fsys, *sheetFile)
+			if err != nil {
+				return Report{}, reportOpts, err
+			}
+			sheetNames, err := readSheetNames(fsys, inputs, *sheetFile, sheetFiles, cleanOpts, *keepDuplicates, *caseSensitive, io.Discard)
+			if err != nil {
+				return Report{}, reportOpts, err
+			}
+			if err := checkAltNames(inputs.AltNames, guildPlayers, *strictAltNames); err != nil {
+				return Report{}, reportOpts, err
+			}
+
+			report, err := buildReport(ctx, guildPlayers, sheetNames, inputs, matchOpts, &reportOpts, settings)
+			return report, reportOpts, err
+		}}
+
+		// Requests get contexts derived from ctx, so Ctrl-C also stops analyses in progress
+		httpServer := &http.Server{
+			Addr:              *serveAddr,
+			Handler:           server.handler(),
+			BaseContext:       func(net.Listener) context.Context { return ctx },
+			ReadHeaderTimeout: 10 * time.Second,
+			ReadTimeout:       30 * time.Second,
+		}
+		stopped := make(chan struct{})
+		go func() {
+			defer close(stopped)
+			<-ctx.Done()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := httpServer.Shutdown(shutdownCtx); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+		}()
+
+		fmt.Fprintf(progress, "Serving the report on %s\n", *serveAddr)
+		if err := httpServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Error: %v", err)
+		}
+		<-stopped
+		fmt.Fprintln(progress, "Stopped serving the report")
+		return
+	}
+
+	inputs, err := loadAnalysisInputs(fsys, *combined, *altNamesFile, &cleanOpts, &matchOpts, progress)
+	if err != nil {
+		exitIfInterrupted(err)
+		log.Fatalf("Error: %v", err)
+	}
+	altNames := inputs.AltNames
+
+	// Online status in an old export is stale, so don't act on it; piped data has no file age
+	if *maxAge > 0 && (*combined != "" || *guildFile != "-") {
+		guildSource := *guildFile
+		if *combined != "" {
+			guildSource = *combined
+		}
+		if err := checkFileAge(fsys, clock, guildSource, *maxAge); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+	}
+
+	// Parse guild file
+	fmt.Fprintln(progress, "Reading guild data...")
+	guildPlayers, err := readGuildPlayers(ctx, fsys, inputs, *guildFile, guildOpts, *caseSensitive, progress)
+	if err != nil {
+		exitIfInterrupted(err)
+		log.Fatalf("Error: %v", err)
+	}
+
+	// Dump mode shows exactly how the guild file was interpreted
+	if *dumpGuild {
+		data, err := json.MarshalIndent(guildPlayers, "", "  ")
+		if err != nil {
+			log.Fatalf("Error encoding guild data: %v", err)
+		}
+		fmt.Println(string(data))
+		return
+	}
+
+	// Listing mode shows which alternative names were picked up for each member
+	if *listAltNames {
+		members := make(map[string]bool)
+		var guildNames []string
+		for _, player := range guildPlayers {
+			members[player.Username] = true
+			if len(altNames.GuildToAlternatives[player.Username]) > 0 || *verbose {
+				guildNames = append(guildNames, player.Username)
+			}
+		}
+		for guildName := range altNames.GuildToAlternatives {
+			if !members[guildName] {
+				guildNames = append(guildNames, guildName)
+			}
+		}
+		sort.Slice(guildNames, func(i, j int) bool {
+			return strings.ToLower(guildNames[i]) < strings.ToLower(guildNames[j])
+		})
+
+		fmt.Printf("\n=== ALTERNATIVE NAMES ===\n")
+		for _, guildName := range guildNames {
+			var alternatives []string
+			for _, alt := range altNames.GuildToAlternatives[guildName] {
+				if altNames.IsFormerName(alt, matchOpts.Now) {
+					alt += " (former)"
+				}
+				alternatives = append(alternatives, alt)
+			}
+			label := guildName
+			if !members[guildName] {
+				label += " (not a guild member)"
+			}
+			if len(alternatives) == 0 {
+				fmt.Printf("  %s: (none)\n", label)
+			} else {
+				fmt.Printf("  %s: %s\n", label, strings.Join(alternatives, ", "))
+			}
+		}
+		return
+	}
+
+	// Fill in roles the guild export doesn't carry
+	if *rolesFile != "" {
+		roles, err := signup.ParseRolesFile(fsys, *rolesFile)
+		if err != nil {
+			log.Fatalf("Error parsing roles file: %v", err)
+		}
+		signup.MergeRoles(guildPlayers, roles)
+		fmt.Fprintf(progress, "Loaded roles for %d players from %s\n", len(roles), *rolesFile)
+	}
+
+	// Membership diff mode shows who joined and left since an older export
+	if *compareOld != "" {
+		oldPlayers, err := signup.ParseGuildFile(fsys, *compareOld, guildOpts)
+		if err != nil {
+			log.Fatalf("Error parsing old guild file: %v", err)
+		}
+
+		joined, left, renamed := signup.DiffMembers(oldPlayers, guildPlayers, altNames)
+
+		fmt.Printf("\n=== MEMBERSHIP CHANGES SINCE %s ===\n", *compareOld)
+		fmt.Printf("Joined (%d):\n", len(joined))
+		for _, name := range joined {
+			fmt.Printf("  %s\n", name)
+		}
+		fmt.Printf("\nLeft (%d):\n", len(left))
+		for _, name := range left {
+			fmt.Printf("  %s\n", name)
+		}
+		if len(renamed) > 0 {
+			fmt.Printf("\nRenamed, per the alternative names (%d):\n", len(renamed))
+			for _, rename := range renamed {
+				fmt.Printf("  %s -> %s\n", rename.OldName, rename.NewName)
+			}
+		}
+		return
+	}
+
+	// Roster comparison mode diffs two guild exports instead of checking the sheet
+	if *compareRostersMode {
+		if *guild2 == "" {
+			log.Fatalf("Error: -compare-rosters needs a second guild file passed with -guild2")
+		}
+		otherPlayers, err := signup.ParseGuildFile(fsys, *guild2, guildOpts)
+		if err != nil {
+			log.Fatalf("Error parsing second guild file: %v", err)
+		}
+
+		onlyFirst, onlySecond, changed := signup.CompareRosters(guildPlayers, otherPlayers)
+
+		fmt.Printf("\n=== ROSTER COMPARISON ===\n")
+		fmt.Printf("Only in the first guild file (%d):\n", len(onlyFirst))
+		for _, name := range onlyFirst {
+			fmt.Printf("  %s\n", name)
+		}
+		fmt.Printf("\nOnly in %s (%d):\n", *guild2, len(onlySecond))
+		for _, name := range onlySecond {
+			fmt.Printf("  %s\n", name)
+		}
+		fmt.Printf("\nChanged status or roles (%d):\n", len(changed))
+		for _, change := range changed {
+			var details []string
+			if change.OldStatus != change.NewStatus {
+				details = append(details, fmt.Sprintf("status %s -> %s", change.OldStatus, change.NewStatus))
+			}
+			if change.OldRoles != change.NewRoles {
+				details = append(details, fmt.Sprintf("roles '%s' -> '%s'", change.OldRoles, change.NewRoles))
+			}
+			fmt.Printf("  %s: %s\n", change.Username, strings.Join(details, ", "))
+		}
+		return
+	}
+
+	// Role auditing compares roles against a stored snapshot, independently of the sheet
+	if *writeBaseline {
+		if *roleBaseline == "" {
+			log.Fatalf("Error: -write-role-baseline needs a file passed with -role-baseline")
+		}
+		if err := writeRoleBaseline(*roleBaseline, guildPlayers); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		fmt.Printf("Wrote roles of %d members to %s\n", len(guildPlayers), *roleBaseline)
+		return
+	}
+	var baseline map[string]string
+	if *roleBaseline != "" {
+		baseline, err = signup.ParseRolesFile(fsys, *roleBaseline)
+		if err != nil {
+			log.Fatalf("Error parsing role baseline: %v", err)
+		}
+	}
+
+	// A guild export much smaller than usual was most likely cut off, so the results can't be trusted
+	if *minRosterFraction > 0 {
+		runs, err := readRosterHistory(fsys, *rosterHistory)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		average, short := rosterShortfall(runs, len(guildPlayers), *minRosterFraction)
+		if short {
+			log.Printf("WARNING: the guild file has %d members, more than %.0f%% below the recent average of %.0f; the export is probably incomplete",
+				len(guildPlayers), *minRosterFraction*100, average)
+		} else {
+			// Only normal-looking runs go into the history, so a truncated export doesn't lower the average
+			runs = append(runs, RosterRun{Timestamp: clock.Now().UTC().Format(time.RFC3339), Members: len(guildPlayers)})
+			if err := writeRosterHistory(*rosterHistory, runs); err != nil {
+				log.Fatalf("Error: %v", err)
+			}
+		}
+	}
+
+	// Without any role data the excluded roles can't be applied, so say so
+	hasRoleData := false
+	for _, player := range guildPlayers {
+		if player.Roles != "" {
+			hasRoleData = true
+			break
+		}
+	}
+	if !hasRoleData && len(guildPlayers) > 0 {
+		log.Printf("Note: the guild data has no roles, so excluded-role filtering is disabled")
+	}
+
+	fmt.Fprintf(progress, "Found %d online players in guild\n", countOnline(guildPlayers, matchOpts.OnlineStatuses))
+
+	// Parse sheet file, or several merged together
+	fmt.Fprintln(progress, "Reading sheet data...")
+	if *gsheetID != "" {
+		if err := syncGoogleSheet(ctx, fsys, clock, googleClient, gsheet, progress); err != nil {
+			exitIfInterrupted(err)
+			log.Fatalf("Error: %v", err)
+		}
+	}
+	sheetFiles, err := expandSheetFiles(fsys, *sheetFile)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	sheetNames, err := readSheetNames(fsys, inputs, *sheetFile, sheetFiles, cleanOpts, *keepDuplicates, *caseSensitive, progress)
+	if err != nil {
+		exitIfInterrupted(err)
+		log.Fatalf("Error: %v", err)
+	}
+
+	// Check mode answers "is this player signed up?" for a single name
+	if *check != "" {
+		var guildNames []string
+		memberName := ""
+		for _, player := range guildPlayers {
+			guildNames = append(guildNames, player.Username)
+			if player.Username == *check || (!*caseSensitive && strings.EqualFold(player.Username, *check)) {
+				memberName = player.Username
+			}
+		}
+
+		// Members are looked up in the sheet, anything else is treated as a sheet name
+		var match signup.MatchResult
+		candidates := guildNames
+		if memberName != "" {
+			match = signup.FindNameMatch(memberName, sheetNames, altNames, inputs.IgnoredNames, matchOpts)
+			candidates = sheetNames
+		} else {
+			match = signup.FindSheetNameMatch(*check, guildNames, altNames, inputs.IgnoredNames, matchOpts)
+		}
+
+		if match.Found {
+			fmt.Printf("%s: MATCHED %s\n", *check, signup.DescribeMatch(match))
+			exitWithVerdict(0, 0, 0, *quiet)
+		}
+
+		if len(match.Candidates) > 0 {
+			fmt.Printf("%s: NOT FOUND, ambiguous between: %s\n", *check, strings.Join(match.Candidates, ", "))
+		} else if closest, distance := signup.FindClosestName(*check, candidates); distance >= 0 {
+			fmt.Printf("%s: NOT FOUND, closest: '%s' (distance %d)\n", *check, closest, distance)
+		} else {
+			fmt.Printf("%s: NOT FOUND\n", *check)
+		}
+		// An unmatched member is missing from the sheet, any other name is extra
+		if memberName != "" {
+			exitWithVerdict(1, 0, 1, *quiet)
+		}
+		exitWithVerdict(0, 1, 1, *quiet)
+	}
+
+	// Explain which different-looking names normalization treats as the same
+	if *dedupeReport {
+		var sheetLines []string
+		if inputs.Sections != nil {
+			sheetLines, err = signup.ReadSheetLines(strings.NewReader(inputs.Sections["sheet"]))
+		} else {
+			sheetLines, err = signup.ReadSheetLinesFiles(fsys, sheetFiles)
+		}
+		if err != nil {
+			log.Fatalf("Error reading sheet file: %v", err)
+		}
+
+		var guildNames []string
+		for _, player := range guildPlayers {
+			guildNames = append(guildNames, player.Username)
+		}
+
+		collisions := signup.FindNormalizationCollisions(guildNames, sheetLines, cleanOpts)
+		fmt.Printf("\n=== NORMALIZATION COLLISIONS ===\n")
+		if len(collisions) == 0 {
+			fmt.Println("  (none)")
+		}
+		for _, collision := range collisions {
+			fmt.Printf("  [%s] %s and %s are equal after %s\n", collision.Scope, collision.First, collision.Second, collision.Step)
+		}
+		return
+	}
+
+	// Validation mode reports problems in the input files instead of running the analysis
+	if *validate {
+		fmt.Println("Validating input files...")
+		issues := signup.FindAliasTypos(altNames)
+		if len(issues) == 0 {
+			fmt.Println("No problems found")
+			return
+		}
+		fmt.Printf("Found %d possible problems:\n", len(issues))
+		for _, issue := range issues {
+			fmt.Printf("  %s\n", issue)
+		}
+		os.Exit(1)
+	}
+
+	// Compare the roster with the sheet
+	fmt.Fprintln(progress, "Analyzing data...")
+
+	if err := checkAltNames(altNames, guildPlayers, *strictAltNames); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	settings.RoleBaseline = baseline
+	report, err := buildReport(ctx, guildPlayers, sheetNames, inputs, matchOpts, &reportOpts, settings)
+	if err != nil {
+		exitIfInterrupted(err)
+		log.Fatalf("Error: %v", err)
+	}
+	missingPlayers, excludedPlayers, guildMatches := report.MissingPlayers, report.ExcludedPlayers, report.Matches
+	sheetPlayersNotInGuild := report.SheetPlayersNotInGuild
+
+	// Identify the run so stored reports can be matched to cron executions
+	if *runID != "" {
+		run := RunInfo{ID: *runID, Timestamp: clock.Now().UTC().Format(time.RFC3339)}
+		if run.ID == "auto" {
+			run.ID, err = newRunID()
+			if err != nil {
+				log.Fatalf("Error: %v", err)
+			}
+		}
+		report.Run = &run
+	}
+
+	// An interrupt after matching still leaves the output files as they were
+	exitIfInterrupted(ctx.Err())
+
+	// Write the output files first, so they are complete whatever else is shown
+	if err := writeOutputSinks(os.Stdout, sinks, report, reportOpts); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if *xlsxFile != "" {
+		var b bytes.Buffer
+		if err := writeXLSXReport(&b, report, reportOpts); err != nil {
+			log.Fatalf("Error: failed to write Excel workbook: %v", err)
+		}
+		if err := writeFileAtomic(*xlsxFile, b.Bytes()); err != nil {
+			log.Fatalf("Error: failed to write %s: %v", *xlsxFile, err)
+		}
+		fmt.Fprintf(progress, "Wrote Excel workbook to %s\n", *xlsxFile)
+	}
+
+	// Notify the officer channel; a failed post is logged but doesn't fail the run
+	if *discordWebhook != "" {
+		client := &http.Client{Timeout: 10 * time.Second}
+		messages := discordMessages(report, reportOpts)
+		for i, message := range messages {
+			if err := postDiscordWebhook(client, *discordWebhook, message); err != nil {
+				log.Printf("Warning: %v", err)
+				break
+			}
+			fmt.Fprintf(progress, "Posted message %d of %d to Discord\n", i+1, len(messages))
+		}
+	}
+
+	// Scheduled jobs can fail when enough online players haven't signed up
+	exitCode := 0
+	if *failOnMissing && len(missingPlayers) >= max(*minMissing, 1) {
+		exitCode = 1
+	}
+
+	// Names-only mode prints just the missing players for use in other scripts
+	if *namesOnly {
+		for _, player := range missingPlayers {
+			fmt.Println(mentionName(player.Username, reportOpts))
+		}
+		exitWithVerdict(len(missingPlayers), len(sheetPlayersNotInGuild), exitCode, *quiet)
+	}
+
+	// Summary CSV mode prints one row per run, to append to an attendance tracking file
+	if *summaryCSV {
+		writer := csv.NewWriter(os.Stdout)
+		if *summaryCSVHeader {
+			writer.Write([]string{"timestamp", "online", "present", "missing", "excluded", "sheet_not_in_guild"})
+		}
+		writer.Write([]string{
+			clock.Now().UTC().Format(time.RFC3339),
+			fmt.Sprint(report.Summary.OnlineMembers),
+			fmt.Sprint(len(guildMatches)),
+			fmt.Sprint(len(missingPlayers)),
+			fmt.Sprint(len(excludedPlayers)),
+			fmt.Sprint(len(sheetPlayersNotInGuild)),
+		})
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			log.Fatalf("Error writing summary CSV: %v", err)
+		}
+		exitWithVerdict(len(missingPlayers), len(sheetPlayersNotInGuild), exitCode, *quiet)
+	}
+
+	// Delta mode prints only what changed since the previous run, for a display refreshed during
+	// a raid; the first run has nothing to compare with, so it prints the full report as a baseline
+	if *deltaOnly {
+		previous, err := readDeltaRun(fsys, *deltaFile)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		current := DeltaRun{
+			Timestamp:       clock.Now().UTC().Format(time.RFC3339),
+			Missing:         signup.Usernames(missingPlayers),
+			SheetNotInGuild: sheetPlayersNotInGuild,
+		}
+		if err := writeDeltaRun(*deltaFile, current); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		if previous != nil {
+			lines := deltaLines(*previous, current, reportOpts)
+			if len(lines) == 0 {
+				fmt.Printf("No changes since %s\n", previous.Timestamp)
+			}
+			for _, line := range lines {
+				fmt.Println(line)
+			}
+			exitWithVerdict(len(missingPlayers), len(sheetPlayersNotInGuild), exitCode, *quiet)
+		}
+		fmt.Fprintln(progress, "No previous run to compare with, printing the full report as a baseline")
+	}
+
+	// Print the full report, unless it went to the output files
+	if len(sinks) == 0 {
+		if reportTemplate != nil {
+			err = writeTemplateReport(os.Stdout, reportTemplate, report, reportOpts)
+		} else {
+			err = writeReport(os.Stdout, *format, report, reportOpts)
+		}
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+	}
+
+	if !*quiet {
+		printVerdict(len(missingPlayers), len(sheetPlayersNotInGuild))
+	}
+
+	// Wait for user input if running from GUI (Windows Explorer double-click);
+	// structured output, templates and report files are read by scripts, which won't press Enter
+	if *format == "text" && len(sinks) == 0 && reportTemplate == nil && !*deltaOnly {
+		// Everything is written, so Ctrl-C may end the wait as usual
+		stop()
+		waitForUserInput()
+	}
+	os.Exit(exitCode)
 }
@@ -0,0 +1,539 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"signup-checker/signup"
+)
+
+func TestTextReportSortsMatches(t *testing.T) {
+	report := Report{
+		Matches: []signup.MatchResult{
+			{Found: true, GuildName: "Xandor", MatchType: "alternative", AlternativeName: "Xan", MatchedName: "Xan"},
+			{Found: true, GuildName: "apple", MatchType: "alternative", AlternativeName: "Appl", MatchedName: "Appl"},
+			{Found: true, GuildName: "Bone", MatchType: "alternative", AlternativeName: "Bonehic", MatchedName: "Bonehic"},
+		},
+	}
+	var buf bytes.Buffer
+	writeTextReport(&buf, report, ReportOptions{})
+
+	var lines []string
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.HasPrefix(line, "Matched: ") {
+			lines = append(lines, line)
+		}
+	}
+	want := []string{
+		"Matched: apple (found as 'Appl' in sheet)",
+		"Matched: Bone (found as 'Bonehic' in sheet)",
+		"Matched: Xandor (found as 'Xan' in sheet)",
+	}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("match lines = %q, want %q", lines, want)
+	}
+}
+
+// withFlagSet swaps in an empty flag set for the test, since main defines the real flags
+func withFlagSet(t *testing.T) *flag.FlagSet {
+	saved := flag.CommandLine
+	flag.CommandLine = flag.NewFlagSet("signup-checker", flag.ContinueOnError)
+	t.Cleanup(func() { flag.CommandLine = saved })
+	return flag.CommandLine
+}
+
+func TestConfigRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"sheet", "data/sheet.txt"},
+		{"sheet", "sheet # with a hash.txt"},
+		{"sheet", "it's.txt"},
+		{"sheet", `C:\signups\sheet.txt`},
+		{"delimiter", "\t"},
+		{"fuzzy-ratio", "0.25"},
+		{"exclude-no-role", "true"},
+	}
+	for _, tt := range tests {
+		// Write the value in each quoting style the config reader accepts
+		encodings := []string{
+			strconv.Quote(tt.value),
+			"'" + strings.ReplaceAll(tt.value, "'", "''") + "'",
+		}
+		if !strings.ContainsAny(tt.value, "#\t'\\") {
+			encodings = append(encodings, tt.value)
+		}
+		for _, encoded := range encodings {
+			flags := withFlagSet(t)
+			got := map[string]*string{
+				"sheet":     flags.String("sheet", "", ""),
+				"delimiter": flags.String("delimiter", "", ""),
+			}
+			fuzzyRatio := flags.Float64("fuzzy-ratio", 0, "")
+			excludeNoRole := flags.Bool("exclude-no-role", false, "")
+
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			config := fmt.Sprintf("# written by the test\n%s: %s # trailing comment\n", tt.name, encoded)
+			if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := applyConfigFile(path); err != nil {
+				t.Fatalf("applyConfigFile(%q) returned error: %v", config, err)
+			}
+
+			var value string
+			switch tt.name {
+			case "fuzzy-ratio":
+				value = strconv.FormatFloat(*fuzzyRatio, 'g', -1, 64)
+			case "exclude-no-role":
+				value = strconv.FormatBool(*excludeNoRole)
+			default:
+				value = *got[tt.name]
+			}
+			if value != tt.value {
+				t.Errorf("config line %q set %s to %q, want %q", strings.TrimSpace(config), tt.name, value, tt.value)
+			}
+		}
+	}
+}
+
+func TestConfigListsAndCommandLine(t *testing.T) {
+	flags := withFlagSet(t)
+	var sinks outputSinks
+	flags.Var(&sinks, "out", "")
+	sheet := flags.String("sheet", "", "")
+	format := flags.String("format", "text", "")
+	if err := flags.Parse([]string{"-sheet", "cli.txt"}); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	config := "sheet: config.txt\nformat: json\nout:\n  - report.txt\n  - report.json:json\n"
+	if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyConfigFile(path); err != nil {
+		t.Fatalf("applyConfigFile returned error: %v", err)
+	}
+
+	if *sheet != "cli.txt" {
+		t.Errorf("sheet = %q, want the command line's %q", *sheet, "cli.txt")
+	}
+	if *format != "json" {
+		t.Errorf("format = %q, want %q", *format, "json")
+	}
+	if want := (outputSinks{{Path: "report.txt"}, {Path: "report.json", Format: "json"}}); !reflect.DeepEqual(sinks, want) {
+		t.Errorf("out = %+v, want %+v", sinks, want)
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	tests := []struct {
+		config string
+		want   string
+	}{
+		{"colour: red\n", `config.yaml:1: unknown setting "colour"`},
+		{"config: other.yaml\n", `config.yaml:1: unknown setting "config"`},
+		{"# comment\nsheet\n", `config.yaml:2: expected "flag-name: value"`},
+		{"- item\n", "config.yaml:1: list item without a key"},
+		{"sheet: \"unterminated\n", "config.yaml:1: unterminated or invalid quoted value"},
+		{"sheet: 'a' b\n", `config.yaml:1: unexpected "b" after quoted value`},
+		{"fuzzy-ratio: lots\n", `config.yaml:1: invalid value "lots" for fuzzy-ratio`},
+	}
+	for _, tt := range tests {
+		flags := withFlagSet(t)
+		flags.String("sheet", "", "")
+		flags.Float64("fuzzy-ratio", 0, "")
+		flags.String("config", "", "")
+
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		if err := os.WriteFile(path, []byte(tt.config), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		err := applyConfigFile(path)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("applyConfigFile(%q) returned error %v, want one containing %q", tt.config, err, tt.want)
+		}
+	}
+}
+
+var update = flag.Bool("update", false, "rewrite the testdata/*.golden files with the current output")
+
+// goldenReport runs the analysis on the guild, sheet and alternative names in testdata/<name>
+// with the default exclusions plus -exclude-no-role
+func goldenReport(t *testing.T, name string) (Report, ReportOptions) {
+	t.Helper()
+	fsys := os.DirFS(filepath.Join("testdata", name))
+	guildPlayers, err := signup.ParseGuildFile(fsys, "guild.txt", signup.GuildOptions{HeaderLines: 1, Strict: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cleanOpts := signup.CleanOptions{Loadouts: make(map[string]string)}
+	sheetNames, err := signup.ParseSheetFile(fsys, "sheet.txt", cleanOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	altNames, err := signup.ParseAlternativeNamesFile(fsys, "sheet-names.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	matchOpts := signup.MatchOptions{
+		ExcludedRoles: signup.DefaultExcludedRoles(),
+		ExcludeNoRole: true,
+		SheetLoadouts: cleanOpts.Loadouts,
+	}
+	var opts ReportOptions
+	report, err := buildReport(context.Background(), guildPlayers, sheetNames, analysisInputs{AltNames: altNames}, matchOpts, &opts, reportSettings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return report, opts
+}
+
+func TestTextReportGolden(t *testing.T) {
+	for _, name := range []string{"no-missing", "excluded", "alternative"} {
+		t.Run(name, func(t *testing.T) {
+			report, opts := goldenReport(t, name)
+			var buf bytes.Buffer
+			writeTextReport(&buf, report, opts)
+
+			golden := filepath.Join("testdata", name+".golden")
+			if *update {
+				if err := os.WriteFile(golden, buf.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v; run go test -update to create it", err)
+			}
+			if got := buf.String(); got != string(want) {
+				t.Errorf("text report differs from %s; run go test -update if the change is intended\ngot:\n%s\nwant:\n%s", golden, got, want)
+			}
+		})
+	}
+}
+
+func TestOutputSinksStdoutAndFile(t *testing.T) {
+	var sinks outputSinks
+	jsonPath := filepath.Join(t.TempDir(), "report.json")
+	for _, value := range []string{"-:text", jsonPath + ":json"} {
+		if err := sinks.Set(value); err != nil {
+			t.Fatalf("Set(%q) returned error: %v", value, err)
+		}
+	}
+	if sinks[0].Path != stdoutSinkPath {
+		t.Fatalf("Set(%q) gave path %q, want stdout", "-:text", sinks[0].Path)
+	}
+
+	report, opts := goldenReport(t, "excluded")
+	savedProgress := progress
+	progress = io.Discard
+	t.Cleanup(func() { progress = savedProgress })
+	var stdout bytes.Buffer
+	if err := writeOutputSinks(&stdout, sinks, report, opts); err != nil {
+		t.Fatalf("writeOutputSinks returned error: %v", err)
+	}
+
+	var text bytes.Buffer
+	writeTextReport(&text, report, opts)
+	if stdout.String() != text.String() {
+		t.Errorf("stdout = %q, want the text report %q", stdout.String(), text.String())
+	}
+	data, err := os.ReadFile(jsonPath)
+	if err != nil {
+		t.Fatalf("JSON sink wasn't written: %v", err)
+	}
+	var written Report
+	if err := json.Unmarshal(data, &written); err != nil {
+		t.Fatalf("JSON sink isn't valid JSON: %v", err)
+	}
+	if got := signup.Usernames(written.MissingPlayers); !reflect.DeepEqual(got, []string{"Apple"}) {
+		t.Errorf("JSON sink lists missing players %q, want %q", got, []string{"Apple"})
+	}
+	if _, err := os.Stat(stdoutSinkPath); err == nil {
+		t.Errorf("a file named %q was created", stdoutSinkPath)
+	}
+}
+
+func TestExpandSheetFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"data/sheet-tanks.txt": {Data: []byte("Xandor\n")},
+		"data/sheet-dps.txt":   {Data: []byte("Bone\n")},
+		"data/sheet.txt":       {Data: []byte("Apple\n")},
+	}
+	tests := []struct {
+		value   string
+		want    []string
+		wantErr string
+	}{
+		{value: "data/sheet.txt", want: []string{"data/sheet.txt"}},
+		{value: "data/sheet-*.txt", want: []string{"data/sheet-dps.txt", "data/sheet-tanks.txt"}},
+		{value: "data/sheet.txt, data/sheet-t*.txt", want: []string{"data/sheet.txt", "data/sheet-tanks.txt"}},
+		{value: "data/roster-*.txt", wantErr: `no sheet files match "data/roster-*.txt"`},
+		{value: "data/sheet-[.txt", wantErr: "invalid sheet pattern"},
+		{value: " , ", wantErr: "no sheet file given"},
+	}
+	for _, tt := range tests {
+		got, err := expandSheetFiles(fsys, tt.value)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expandSheetFiles(%q) returned error %v, want one containing %q", tt.value, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("expandSheetFiles(%q) returned error: %v", tt.value, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("expandSheetFiles(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestSheetPlayersCountsDeduplicatedNames(t *testing.T) {
+	fsys := fstest.MapFS{
+		"data/sheet-tanks.txt": {Data: []byte("Xandor\nBone\n")},
+		"data/sheet-dps.txt":   {Data: []byte("xandor (Longbow)\nApple\nApple\n")},
+	}
+	sheetFiles, err := expandSheetFiles(fsys, "data/sheet-*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sheetNames, err := readSheetNames(fsys, analysisInputs{}, "data/sheet-*.txt", sheetFiles, signup.CleanOptions{}, false, false, io.Discard)
+	if err != nil {
+		t.Fatalf("readSheetNames returned error: %v", err)
+	}
+
+	guildPlayers := []signup.Player{
+		{Username: "Xandor", Status: "Online"},
+		{Username: "Bone", Status: "Online"},
+		{Username: "Apple", Status: "Online"},
+	}
+	var opts ReportOptions
+	report, err := buildReport(context.Background(), guildPlayers, sheetNames, analysisInputs{AltNames: signup.NewAlternativeNames()},
+		signup.MatchOptions{}, &opts, reportSettings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Summary.SheetPlayers != 3 {
+		t.Errorf("Players in sheet = %d from %q, want 3", report.Summary.SheetPlayers, sheetNames)
+	}
+	if report.Summary.Missing != 0 {
+		t.Errorf("Online players missing = %d, want 0", report.Summary.Missing)
+	}
+}
+
+// fixedClock is a Clock stopped at one time
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+// fakeGoogle serves the token endpoint and the Sheets API, checking the signed token request
+type fakeGoogle struct {
+	t         *testing.T
+	key       *rsa.PrivateKey
+	now       time.Time
+	sheetDown bool // answer the values request with 503
+}
+
+func (g *fakeGoogle) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/token":
+		if err := r.ParseForm(); err != nil {
+			g.t.Errorf("token request: %v", err)
+		}
+		parts := strings.Split(r.PostForm.Get("assertion"), ".")
+		if len(parts) != 3 {
+			http.Error(w, "malformed assertion", http.StatusBadRequest)
+			return
+		}
+		signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+		digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		if err := rsa.VerifyPKCS1v15(&g.key.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+			http.Error(w, "bad signature", http.StatusUnauthorized)
+			return
+		}
+		var claims struct {
+			Issuer   string `json:"iss"`
+			IssuedAt int64  `json:"iat"`
+		}
+		payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+		if err := json.Unmarshal(payload, &claims); err != nil {
+			g.t.Errorf("token claims: %v", err)
+		}
+		if claims.IssuedAt != g.now.Unix() || claims.Issuer != "checker@example.iam.gserviceaccount.com" {
+			g.t.Errorf("token claims = %+v, want iat %d from the clock", claims, g.now.Unix())
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "test-token"})
+	case strings.HasPrefix(r.URL.Path, "/v4/spreadsheets/sheet-id/values/"):
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if g.sheetDown {
+			http.Error(w, "backend error", http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"values": [][]string{{"Xandor"}, {}, {"Bone", " "}}})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// googleTestSetup starts a fake Google and writes a service account key file for it
+func googleTestSetup(t *testing.T) (*fakeGoogle, googleSheet, *http.Client) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	google := &fakeGoogle{t: t, key: key, now: time.Date(2024, 3, 1, 18, 0, 0, 0, time.UTC)}
+	server := httptest.NewServer(google)
+	t.Cleanup(server.Close)
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credentials, err := json.Marshal(serviceAccountKey{
+		ClientEmail: "checker@example.iam.gserviceaccount.com",
+		PrivateKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		TokenURI:    server.URL + "/token",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	sheet := googleSheet{
+		SpreadsheetID: "sheet-id",
+		Range:         "Roster!A:B",
+		Credentials:   filepath.Join(dir, "credentials.json"),
+		Cache:         filepath.Join(dir, "gsheet-cache.txt"),
+		API:           server.URL + "/v4/spreadsheets",
+	}
+	if err := os.WriteFile(sheet.Credentials, credentials, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return google, sheet, server.Client()
+}
+
+func TestSyncGoogleSheet(t *testing.T) {
+	google, sheet, client := googleTestSetup(t)
+	clock := fixedClock(google.now)
+
+	// A successful fetch writes the non-empty cells to the cache
+	if err := syncGoogleSheet(context.Background(), osFS{}, clock, client, sheet, io.Discard); err != nil {
+		t.Fatalf("syncGoogleSheet returned error: %v", err)
+	}
+	cache, err := os.ReadFile(sheet.Cache)
+	if err != nil {
+		t.Fatalf("cache wasn't written: %v", err)
+	}
+	if want := "Xandor\nBone\n"; string(cache) != want {
+		t.Errorf("cache = %q, want %q", cache, want)
+	}
+
+	// An API error falls back to the cache from the last fetch
+	google.sheetDown = true
+	if err := syncGoogleSheet(context.Background(), osFS{}, clock, client, sheet, io.Discard); err != nil {
+		t.Fatalf("syncGoogleSheet with the API down returned error %v, want the cache used", err)
+	}
+	if after, _ := os.ReadFile(sheet.Cache); string(after) != string(cache) {
+		t.Errorf("cache after a failed fetch = %q, want it kept as %q", after, cache)
+	}
+
+	// Without a cache the API error is returned
+	os.Remove(sheet.Cache)
+	err = syncGoogleSheet(context.Background(), osFS{}, clock, client, sheet, io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("syncGoogleSheet without a cache returned error %v, want the API's 503", err)
+	}
+}
+
+func TestGoogleAccessTokenBadCredentials(t *testing.T) {
+	google, sheet, client := googleTestSetup(t)
+	clock := fixedClock(google.now)
+	dir := filepath.Dir(sheet.Credentials)
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(otherKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name        string
+		credentials string
+		want        string
+	}{
+		{"not JSON", "not json", "failed to parse Google credentials"},
+		{"no private key", `{"client_email": "checker@example.iam.gserviceaccount.com"}`, "have no private key"},
+		{"wrong key", mustJSON(t, serviceAccountKey{
+			ClientEmail: "checker@example.iam.gserviceaccount.com",
+			PrivateKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+			TokenURI:    strings.TrimSuffix(sheet.API, "/v4/spreadsheets") + "/token",
+		}), "401"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "bad.json")
+			if err := os.WriteFile(path, []byte(tt.credentials), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			_, err := googleAccessToken(context.Background(), osFS{}, clock, client, path)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("googleAccessToken returned error %v, want one containing %q", err, tt.want)
+			}
+
+			// With no cache to fall back on, the run fails with the credentials error
+			sheet := sheet
+			sheet.Credentials = path
+			if err := syncGoogleSheet(context.Background(), osFS{}, clock, client, sheet, io.Discard); err == nil {
+				t.Errorf("syncGoogleSheet with bad credentials returned no error")
+			}
+		})
+	}
+
+	if _, err := googleAccessToken(context.Background(), osFS{}, clock, client, filepath.Join(dir, "missing.json")); err == nil ||
+		!strings.Contains(err.Error(), "failed to read Google credentials") {
+		t.Errorf("googleAccessToken with a missing file returned error %v", err)
+	}
+}
+
+func mustJSON(t *testing.T, v any) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -0,0 +1,508 @@
+package signup
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Usernames returns the usernames of the given players
+func Usernames(players []Player) []string {
+	names := make([]string, 0, len(players))
+	for _, player := range players {
+		names = append(names, player.Username)
+	}
+	return names
+}
+
+// RoleChange is a member whose roles differ from the role baseline
+type RoleChange struct {
+	Username string `json:"username"`
+	OldRoles string `json:"oldRoles"`
+	NewRoles string `json:"newRoles"`
+}
+
+// FindRoleChanges compares players' roles against a baseline, ignoring role order and case;
+// members missing from the baseline are skipped since there is nothing to compare
+func FindRoleChanges(players []Player, baseline map[string]string) []RoleChange {
+	normalize := func(roles string) string {
+		var list []string
+		for _, role := range strings.Split(roles, ";") {
+			if role = strings.ToLower(strings.TrimSpace(role)); role != "" {
+				list = append(list, role)
+			}
+		}
+		sort.Strings(list)
+		return strings.Join(list, ";")
+	}
+
+	var changes []RoleChange
+	for _, player := range players {
+		oldRoles, exists := baseline[strings.ToLower(player.Username)]
+		if exists && normalize(oldRoles) != normalize(player.Roles) {
+			changes = append(changes, RoleChange{Username: player.Username, OldRoles: oldRoles, NewRoles: player.Roles})
+		}
+	}
+
+	return changes
+}
+
+// RoleGroup is the players sharing a role, for listing missing players by role
+type RoleGroup struct {
+	Role    string   `json:"role"` // "" for players without any role
+	Players []Player `json:"players"`
+}
+
+// GroupByRole buckets players by their first role, or by every role they have when allRoles
+// is set; groups are sorted by role ignoring case, with players without a role last
+func GroupByRole(players []Player, allRoles bool) []RoleGroup {
+	var groups []RoleGroup
+	index := make(map[string]int) // lowercase role -> position in groups
+	add := func(role string, player Player) {
+		key := strings.ToLower(role)
+		i, exists := index[key]
+		if !exists {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, RoleGroup{Role: role})
+		}
+		groups[i].Players = append(groups[i].Players, player)
+	}
+
+	for _, player := range players {
+		roles := player.RoleList()
+		if len(roles) == 0 {
+			add("", player)
+			continue
+		}
+		if !allRoles {
+			roles = roles[:1]
+		}
+		for _, role := range roles {
+			add(role, player)
+		}
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		if (groups[i].Role == "") != (groups[j].Role == "") {
+			return groups[j].Role == ""
+		}
+		return strings.ToLower(groups[i].Role) < strings.ToLower(groups[j].Role)
+	})
+	return groups
+}
+
+// SortOrders are the orders the result lists can be put in by SortResult
+var SortOrders = []string{"input", "name", "role"}
+
+// SortResult orders the missing, excluded and not-in-guild lists: "name" alphabetically ignoring
+// case, "role" by first role with players without one last and then by name, and "input" leaves
+// them in file order. Sheet names have no role, so "role" lists them by name.
+func SortResult(result *AnalysisResult, order string) {
+	if order != "name" && order != "role" {
+		return
+	}
+	less := func(nameA, rolesA, nameB, rolesB string) bool {
+		if order == "role" {
+			roleA, roleB := firstRole(rolesA), firstRole(rolesB)
+			if (roleA == "") != (roleB == "") {
+				return roleB == ""
+			}
+			if roleA != roleB {
+				return roleA < roleB
+			}
+		}
+		return strings.ToLower(nameA) < strings.ToLower(nameB)
+	}
+
+	missing := result.MissingPlayers
+	sort.SliceStable(missing, func(i, j int) bool {
+		return less(missing[i].Username, missing[i].Roles, missing[j].Username, missing[j].Roles)
+	})
+	excluded := result.ExcludedPlayers
+	sort.SliceStable(excluded, func(i, j int) bool {
+		return less(excluded[i].Username, excluded[i].Roles, excluded[j].Username, excluded[j].Roles)
+	})
+	notInGuild := result.SheetPlayersNotInGuild
+	sort.SliceStable(notInGuild, func(i, j int) bool {
+		return less(notInGuild[i], "", notInGuild[j], "")
+	})
+}
+
+// firstRole returns the first of the semicolon-separated roles in lowercase, or "" without any
+func firstRole(roles string) string {
+	if list := (Player{Roles: roles}).RoleList(); len(list) > 0 {
+		return strings.ToLower(list[0])
+	}
+	return ""
+}
+
+// FindMissingRequiredRole returns the online players that don't have the required role (case-insensitive)
+func FindMissingRequiredRole(players []Player, requiredRole string, onlineStatuses []string) []string {
+	var missing []string
+	for _, player := range players {
+		if !player.IsOnline(onlineStatuses) {
+			continue
+		}
+		hasRole := false
+		for _, role := range player.RoleList() {
+			if strings.EqualFold(role, requiredRole) {
+				hasRole = true
+				break
+			}
+		}
+		if !hasRole {
+			missing = append(missing, player.Username)
+		}
+	}
+	return missing
+}
+
+// FindStaleOnline returns the online players whose last online time is before the cutoff, since
+// their online status is likely stale, e.g. from a crashed client; players without a time are skipped
+func FindStaleOnline(players []Player, onlineStatuses []string, cutoff time.Time) []Player {
+	var stale []Player
+	for _, player := range players {
+		if player.IsOnline(onlineStatuses) && !player.LastOnline.IsZero() && player.LastOnline.Before(cutoff) {
+			stale = append(stale, player)
+		}
+	}
+	return stale
+}
+
+// AliasSuggestion pairs an online player missing from the sheet with a similar sheet name
+// that matched no guild member, which likely refer to the same person
+type AliasSuggestion struct {
+	GuildName string
+	SheetName string
+	Distance  int
+}
+
+// FindAliasSuggestions finds close pairs across the two discrepancy lists
+func FindAliasSuggestions(missingPlayers []string, sheetPlayersNotInGuild []string) []AliasSuggestion {
+	var suggestions []AliasSuggestion
+
+	for _, guildName := range missingPlayers {
+		guildNameLower := strings.ToLower(guildName)
+		best := AliasSuggestion{Distance: -1}
+
+		for _, sheetName := range sheetPlayersNotInGuild {
+			// Scale the allowed distance with the name length so short names don't pair up by chance
+			shorter := min(utf8.RuneCountInString(guildName), utf8.RuneCountInString(sheetName))
+			maxDistance := max(1, shorter/3)
+
+			distance := Levenshtein(guildNameLower, strings.ToLower(sheetName))
+			if distance <= maxDistance && (best.Distance < 0 || distance < best.Distance) {
+				best = AliasSuggestion{GuildName: guildName, SheetName: sheetName, Distance: distance}
+			}
+		}
+
+		if best.Distance >= 0 {
+			suggestions = append(suggestions, best)
+		}
+	}
+
+	return suggestions
+}
+
+// FindDidYouMean pairs each sheet name that matched no guild member with the closest guild
+// name, when it is close enough to be a misspelling; names without a close guild name are left out
+func FindDidYouMean(sheetPlayersNotInGuild []string, guildNames []string) map[string]string {
+	suggestions := make(map[string]string)
+	for _, sheetName := range sheetPlayersNotInGuild {
+		closest, distance := FindClosestName(sheetName, guildNames)
+		if closest == "" {
+			continue
+		}
+		// Scale the allowed distance with the name length, like FindAliasSuggestions
+		shorter := min(utf8.RuneCountInString(sheetName), utf8.RuneCountInString(closest))
+		if distance <= max(1, shorter/3) {
+			suggestions[sheetName] = closest
+		}
+	}
+	return suggestions
+}
+
+// MissReasonOrder are the explanations given by ClassifyMisses, in the order they are reported
+var MissReasonOrder = []string{"likely typo", "likely missing alias", "likely no-show"}
+
+// MissReason explains why an online player was not found in the sheet, based on the closest
+// sheet name that matched nobody
+type MissReason struct {
+	Name     string `json:"name"`
+	Reason   string `json:"reason"`            // one of MissReasonOrder
+	Closest  string `json:"closest,omitempty"` // closest unmatched sheet name
+	Distance int    `json:"distance,omitempty"`
+}
+
+// ClassifyMisses runs the fuzzy comparison in diagnostic mode: a missing player with an unmatched
+// sheet name a couple of edits away probably made a typo, one whose name contains or is contained
+// in an unmatched sheet name probably signed up under an alias, and anyone else probably didn't sign up
+func ClassifyMisses(missingPlayers []string, sheetPlayersNotInGuild []string) []MissReason {
+	var reasons []MissReason
+	for _, name := range missingPlayers {
+		miss := MissReason{Name: name, Reason: "likely no-show"}
+		closest, distance := FindClosestName(name, sheetPlayersNotInGuild)
+		if closest != "" {
+			miss.Closest, miss.Distance = closest, distance
+		}
+
+		nameLower := strings.ToLower(name)
+		if closest != "" && distance <= 2 {
+			miss.Reason = "likely typo"
+		} else {
+			for _, sheetName := range sheetPlayersNotInGuild {
+				sheetNameLower := strings.ToLower(sheetName)
+				if utf8.RuneCountInString(sheetName) >= 3 && utf8.RuneCountInString(name) >= 3 &&
+					(strings.Contains(sheetNameLower, nameLower) || strings.Contains(nameLower, sheetNameLower)) {
+					miss.Reason = "likely missing alias"
+					miss.Closest, miss.Distance = sheetName, Levenshtein(nameLower, sheetNameLower)
+					break
+				}
+			}
+		}
+		reasons = append(reasons, miss)
+	}
+	return reasons
+}
+
+// Discrepancy is a single problem found by the analysis, ranked by how urgently it needs attention
+type Discrepancy struct {
+	Name     string
+	Problem  string
+	Severity string // "high", "medium" or "low"
+	Weight   int    // highest role weight of a missing player, -1 for a sheet entry
+}
+
+// ClassifyDiscrepancies combines both discrepancy lists, most urgent first. A missing player's
+// severity follows their heaviest role in roleWeights (lowercase role names): 2 or more is high,
+// like an officer, 1 is medium, and 0 is low, like a recruit. Roles not listed weigh 1 and
+// players without a role 0. A stale sheet entry is only housekeeping, so it is always low and
+// comes after the missing players of the same severity.
+func ClassifyDiscrepancies(missingPlayers []Player, sheetPlayersNotInGuild []string, roleWeights map[string]int) []Discrepancy {
+	var discrepancies []Discrepancy
+
+	for _, player := range missingPlayers {
+		weight := 0
+		for i, role := range player.RoleList() {
+			roleWeight, exists := roleWeights[strings.ToLower(role)]
+			if !exists {
+				roleWeight = 1
+			}
+			if i == 0 || roleWeight > weight {
+				weight = roleWeight
+			}
+		}
+		severity := "low"
+		if weight >= 2 {
+			severity = "high"
+		} else if weight == 1 {
+			severity = "medium"
+		}
+		discrepancies = append(discrepancies, Discrepancy{Name: player.Username, Problem: "online but not in sheet", Severity: severity, Weight: weight})
+	}
+	for _, name := range sheetPlayersNotInGuild {
+		discrepancies = append(discrepancies, Discrepancy{Name: name, Problem: "in sheet but not in guild", Severity: "low", Weight: -1})
+	}
+
+	severityRank := map[string]int{"high": 0, "medium": 1, "low": 2}
+	sort.SliceStable(discrepancies, func(i, j int) bool {
+		if severityRank[discrepancies[i].Severity] != severityRank[discrepancies[j].Severity] {
+			return severityRank[discrepancies[i].Severity] < severityRank[discrepancies[j].Severity]
+		}
+		return discrepancies[i].Weight > discrepancies[j].Weight
+	})
+	return discrepancies
+}
+
+// FindDanglingAliases returns the guild names in the alternative name mappings that aren't
+// in the roster (case-insensitive), because the member left, was renamed or the name has a
+// typo, so their aliases can never match
+func FindDanglingAliases(altNames *AlternativeNames, guildPlayers []Player) []string {
+	members := make(map[string]bool)
+	for _, player := range guildPlayers {
+		members[strings.ToLower(player.Username)] = true
+	}
+
+	var dangling []string
+	for guildName := range altNames.GuildToAlternatives {
+		if !members[strings.ToLower(guildName)] {
+			dangling = append(dangling, guildName)
+		}
+	}
+
+	sort.Strings(dangling)
+	return dangling
+}
+
+// FindAliasConflicts flags alternative names that make matching ambiguous: one claimed by several
+// guild names, where the last mapping silently wins, one that is a guild member's own username,
+// and one that is itself the guild name of another mapping, chaining the two (all ignoring case)
+func FindAliasConflicts(altNames *AlternativeNames, guildPlayers []Player) []string {
+	members := make(map[string]string)
+	for _, player := range guildPlayers {
+		members[foldName(player.Username)] = player.Username
+	}
+	mapped := make(map[string]string)
+	for guildName := range altNames.GuildToAlternatives {
+		mapped[foldName(guildName)] = guildName
+	}
+
+	// Folded alternative name -> folded guild names claiming it -> their spelling
+	claims := make(map[string]map[string]string)
+	spellings := make(map[string]string)
+	for guildName, alternatives := range altNames.GuildToAlternatives {
+		for _, alt := range alternatives {
+			key := foldName(alt)
+			if claims[key] == nil {
+				claims[key] = make(map[string]string)
+			}
+			claims[key][foldName(guildName)] = guildName
+			if spelling, seen := spellings[key]; !seen || alt < spelling {
+				spellings[key] = alt
+			}
+		}
+	}
+
+	var issues []string
+	for key, owners := range claims {
+		alt := spellings[key]
+		guildNames := make([]string, 0, len(owners))
+		for _, guildName := range owners {
+			guildNames = append(guildNames, guildName)
+		}
+		sort.Strings(guildNames)
+
+		if len(guildNames) > 1 {
+			issues = append(issues, fmt.Sprintf("alternative name '%s' is claimed by %s", alt, strings.Join(guildNames, ", ")))
+		}
+		// A member listing their own name as an alternative is redundant but harmless
+		if _, own := owners[key]; own {
+			continue
+		}
+		if member, exists := members[key]; exists {
+			issues = append(issues, fmt.Sprintf("alternative name '%s' of %s is the username of guild member %s",
+				alt, strings.Join(guildNames, ", "), member))
+		} else if target, exists := mapped[key]; exists {
+			issues = append(issues, fmt.Sprintf("alternative name '%s' of %s is mapped to alternative names of its own as %s (chained mapping)",
+				alt, strings.Join(guildNames, ", "), target))
+		}
+	}
+
+	sort.Strings(issues)
+	return issues
+}
+
+// RosterChange describes a member whose status or roles differ between two guild exports
+type RosterChange struct {
+	Username  string
+	OldStatus string
+	NewStatus string
+	OldRoles  string
+	NewRoles  string
+}
+
+// CompareRosters diffs two guild exports by username (case-insensitive), returning the
+// members only in the first, those only in the second, and those whose status or roles changed
+func CompareRosters(first []Player, second []Player) ([]string, []string, []RosterChange) {
+	secondByName := make(map[string]Player)
+	for _, player := range second {
+		secondByName[strings.ToLower(player.Username)] = player
+	}
+
+	var onlyFirst []string
+	var changed []RosterChange
+	firstNames := make(map[string]bool)
+
+	for _, player := range first {
+		firstNames[strings.ToLower(player.Username)] = true
+
+		other, exists := secondByName[strings.ToLower(player.Username)]
+		if !exists {
+			onlyFirst = append(onlyFirst, player.Username)
+			continue
+		}
+
+		if player.Status != other.Status || player.Roles != other.Roles {
+			changed = append(changed, RosterChange{
+				Username:  player.Username,
+				OldStatus: player.Status,
+				NewStatus: other.Status,
+				OldRoles:  player.Roles,
+				NewRoles:  other.Roles,
+			})
+		}
+	}
+
+	var onlySecond []string
+	for _, player := range second {
+		if !firstNames[strings.ToLower(player.Username)] {
+			onlySecond = append(onlySecond, player.Username)
+		}
+	}
+
+	return onlyFirst, onlySecond, changed
+}
+
+// Rename is a member who left under one name and joined under another
+type Rename struct {
+	OldName string
+	NewName string
+}
+
+// DiffMembers compares an older guild export with the current one by username (case-insensitive),
+// returning who joined and who left since; a member who left under an alternative name of
+// someone who joined is returned as renamed instead of in both lists
+func DiffMembers(old []Player, current []Player, altNames *AlternativeNames) ([]string, []string, []Rename) {
+	left, joined, _ := CompareRosters(old, current)
+
+	joinedNames := make(map[string]string)
+	for _, name := range joined {
+		joinedNames[strings.ToLower(name)] = name
+	}
+
+	var stillLeft []string
+	var renames []Rename
+	renamedTo := make(map[string]bool)
+	for _, name := range left {
+		guildName, exists := altNames.AlternativeToGuild[foldName(name)]
+		newName, joinedAs := joinedNames[strings.ToLower(guildName)]
+		if exists && joinedAs && !renamedTo[newName] {
+			renames = append(renames, Rename{OldName: name, NewName: newName})
+			renamedTo[newName] = true
+			continue
+		}
+		stillLeft = append(stillLeft, name)
+	}
+
+	var stillJoined []string
+	for _, name := range joined {
+		if !renamedTo[name] {
+			stillJoined = append(stillJoined, name)
+		}
+	}
+
+	return stillJoined, stillLeft, renames
+}
+
+// FindAliasTypos flags alternative names of the same guild member that are one edit apart,
+// since one of them is most likely a typo of the other
+func FindAliasTypos(altNames *AlternativeNames) []string {
+	var issues []string
+
+	for guildName, alternatives := range altNames.GuildToAlternatives {
+		for i := 0; i < len(alternatives); i++ {
+			for j := i + 1; j < len(alternatives); j++ {
+				if Levenshtein(strings.ToLower(alternatives[i]), strings.ToLower(alternatives[j])) == 1 {
+					issues = append(issues, fmt.Sprintf("%s: alternative names '%s' and '%s' differ by one character (possible typo)",
+						guildName, alternatives[i], alternatives[j]))
+				}
+			}
+		}
+	}
+
+	sort.Strings(issues)
+	return issues
+}
@@ -0,0 +1,271 @@
+package signup
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// groupSummary renders role groups as "Role: name, name" lines for comparing
+func groupSummary(groups []RoleGroup) []string {
+	var lines []string
+	for _, group := range groups {
+		lines = append(lines, group.Role+": "+strings.Join(Usernames(group.Players), ", "))
+	}
+	return lines
+}
+
+func TestGroupByRole(t *testing.T) {
+	players := []Player{
+		{Username: "Xandor", Roles: "Tank;Officer"},
+		{Username: "Bone", Roles: ""},
+		{Username: "Apple", Roles: "officer"},
+		{Username: "Tea", Roles: "Healer"},
+	}
+	tests := []struct {
+		name     string
+		allRoles bool
+		want     []string
+	}{
+		{
+			name: "first role",
+			want: []string{"Healer: Tea", "officer: Apple", "Tank: Xandor", ": Bone"},
+		},
+		{
+			name:     "all roles",
+			allRoles: true,
+			want:     []string{"Healer: Tea", "Officer: Xandor, Apple", "Tank: Xandor", ": Bone"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := groupSummary(GroupByRole(players, tt.allRoles))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GroupByRole(allRoles=%v) = %q, want %q", tt.allRoles, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindAliasConflicts(t *testing.T) {
+	guild := []Player{{Username: "Xandor"}, {Username: "Bone"}, {Username: "Apple"}}
+	tests := []struct {
+		name     string
+		altNames string
+		want     []string
+	}{
+		{
+			name:     "no conflicts",
+			altNames: "Xandor: Xan\nBone: Bonehic, bone\n",
+		},
+		{
+			name:     "claimed by two members",
+			altNames: "Xandor: Xan\nBone: xan\n",
+			want:     []string{"alternative name 'Xan' is claimed by Bone, Xandor"},
+		},
+		{
+			name:     "username of another member",
+			altNames: "Xandor: Apple\n",
+			want:     []string{"alternative name 'Apple' of Xandor is the username of guild member Apple"},
+		},
+		{
+			name:     "chained mapping",
+			altNames: "Xandor: Tea\nTea: Cup\n",
+			want:     []string{"alternative name 'Tea' of Xandor is mapped to alternative names of its own as Tea (chained mapping)"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			altNames, err := ParseAlternativeNames(strings.NewReader(tt.altNames))
+			if err != nil {
+				t.Fatalf("ParseAlternativeNames returned error: %v", err)
+			}
+			got := FindAliasConflicts(altNames, guild)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FindAliasConflicts = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffMembers(t *testing.T) {
+	players := func(names ...string) []Player {
+		var list []Player
+		for _, name := range names {
+			list = append(list, Player{Username: name, Status: "Online"})
+		}
+		return list
+	}
+	tests := []struct {
+		name        string
+		old         []Player
+		current     []Player
+		altNames    string
+		wantJoined  []string
+		wantLeft    []string
+		wantRenames []Rename
+	}{
+		{
+			name:    "unchanged, ignoring case",
+			old:     players("Xandor", "Bone"),
+			current: players("xandor", "Bone"),
+		},
+		{
+			name:       "joins and leaves",
+			old:        players("Xandor", "Bone"),
+			current:    players("Xandor", "Apple", "Tea"),
+			wantJoined: []string{"Apple", "Tea"},
+			wantLeft:   []string{"Bone"},
+		},
+		{
+			name:        "rename through an alternative name",
+			old:         players("Xandor", "OldBone"),
+			current:     players("Xandor", "Bone"),
+			altNames:    "Bone: OldBone\n",
+			wantRenames: []Rename{{OldName: "OldBone", NewName: "Bone"}},
+		},
+		{
+			name:        "rename next to a real join",
+			old:         players("OldBone"),
+			current:     players("Bone", "Apple"),
+			altNames:    "Bone: oldbone\n",
+			wantJoined:  []string{"Apple"},
+			wantRenames: []Rename{{OldName: "OldBone", NewName: "Bone"}},
+		},
+		{
+			name:       "alternative name of someone who didn't join",
+			old:        players("OldBone"),
+			current:    players("Apple"),
+			altNames:   "Bone: OldBone\n",
+			wantJoined: []string{"Apple"},
+			wantLeft:   []string{"OldBone"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			altNames, err := ParseAlternativeNames(strings.NewReader(tt.altNames))
+			if err != nil {
+				t.Fatalf("ParseAlternativeNames returned error: %v", err)
+			}
+			joined, left, renames := DiffMembers(tt.old, tt.current, altNames)
+			if !reflect.DeepEqual(joined, tt.wantJoined) {
+				t.Errorf("joined = %q, want %q", joined, tt.wantJoined)
+			}
+			if !reflect.DeepEqual(left, tt.wantLeft) {
+				t.Errorf("left = %q, want %q", left, tt.wantLeft)
+			}
+			if !reflect.DeepEqual(renames, tt.wantRenames) {
+				t.Errorf("renames = %+v, want %+v", renames, tt.wantRenames)
+			}
+		})
+	}
+}
+
+func TestSortResult(t *testing.T) {
+	newResult := func() AnalysisResult {
+		return AnalysisResult{
+			MissingPlayers: []Player{
+				{Username: "Xandor", Roles: "Tank"},
+				{Username: "bone", Roles: ""},
+				{Username: "Apple", Roles: "tank;Healer"},
+				{Username: "Tea", Roles: "Healer"},
+			},
+			ExcludedPlayers: []ExcludedPlayer{
+				{Username: "Zu", Roles: "Officer"},
+				{Username: "mir", Roles: "Guild Master"},
+			},
+			SheetPlayersNotInGuild: []string{"Stranger", "alien", "Visitor"},
+		}
+	}
+	tests := []struct {
+		order          string
+		wantMissing    []string
+		wantExcluded   []string
+		wantNotInGuild []string
+	}{
+		{
+			order:          "input",
+			wantMissing:    []string{"Xandor", "bone", "Apple", "Tea"},
+			wantExcluded:   []string{"Zu", "mir"},
+			wantNotInGuild: []string{"Stranger", "alien", "Visitor"},
+		},
+		{
+			order:          "name",
+			wantMissing:    []string{"Apple", "bone", "Tea", "Xandor"},
+			wantExcluded:   []string{"mir", "Zu"},
+			wantNotInGuild: []string{"alien", "Stranger", "Visitor"},
+		},
+		{
+			// By first role ignoring case, then name, with players without a role last
+			order:          "role",
+			wantMissing:    []string{"Tea", "Apple", "Xandor", "bone"},
+			wantExcluded:   []string{"mir", "Zu"},
+			wantNotInGuild: []string{"alien", "Stranger", "Visitor"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.order, func(t *testing.T) {
+			result := newResult()
+			SortResult(&result, tt.order)
+
+			var excluded []string
+			for _, player := range result.ExcludedPlayers {
+				excluded = append(excluded, player.Username)
+			}
+			if got := Usernames(result.MissingPlayers); !reflect.DeepEqual(got, tt.wantMissing) {
+				t.Errorf("missing = %q, want %q", got, tt.wantMissing)
+			}
+			if !reflect.DeepEqual(excluded, tt.wantExcluded) {
+				t.Errorf("excluded = %q, want %q", excluded, tt.wantExcluded)
+			}
+			if !reflect.DeepEqual(result.SheetPlayersNotInGuild, tt.wantNotInGuild) {
+				t.Errorf("not in guild = %q, want %q", result.SheetPlayersNotInGuild, tt.wantNotInGuild)
+			}
+		})
+	}
+}
+
+func TestClassifyDiscrepancies(t *testing.T) {
+	missing := []Player{
+		{Username: "Recruit", Roles: "Recruit"},
+		{Username: "Xandor", Roles: "Member;Officer"},
+		{Username: "Nobody", Roles: ""},
+		{Username: "Bone", Roles: "Member"},
+		{Username: "Caller", Roles: "Shotcaller"},
+	}
+	sheet := []string{"Stranger"}
+	tests := []struct {
+		name    string
+		weights map[string]int
+		want    []string
+	}{
+		{
+			name:    "default weights",
+			weights: DefaultRoleWeights(),
+			want: []string{
+				"Xandor high 3", "Bone medium 1", "Caller medium 1",
+				"Recruit low 0", "Nobody low 0", "Stranger low -1",
+			},
+		},
+		{
+			name:    "configured weights",
+			weights: map[string]int{"shotcaller": 5, "officer": 2, "member": 0},
+			want: []string{
+				"Caller high 5", "Xandor high 2", "Recruit medium 1",
+				"Nobody low 0", "Bone low 0", "Stranger low -1",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, d := range ClassifyDiscrepancies(missing, sheet, tt.weights) {
+				got = append(got, fmt.Sprintf("%s %s %d", d.Name, d.Severity, d.Weight))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ClassifyDiscrepancies = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
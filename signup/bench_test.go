@@ -0,0 +1,106 @@
+package signup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// generatedInput is a parsed guild, sheet and alternative names from testdata/generate.go
+type generatedInput struct {
+	players  []Player
+	sheet    []string
+	altNames *AlternativeNames
+}
+
+var (
+	generatedMu     sync.Mutex
+	generatedInputs = make(map[string]generatedInput)
+)
+
+// generate runs testdata/generate.go once per size and parses what it writes, so every
+// benchmark of that size measures the same input
+func generate(b *testing.B, players, sheet int) generatedInput {
+	b.Helper()
+	generatedMu.Lock()
+	defer generatedMu.Unlock()
+
+	size := fmt.Sprintf("%d/%d", players, sheet)
+	if input, ok := generatedInputs[size]; ok {
+		return input
+	}
+
+	dir, err := os.MkdirTemp("", "signup-bench")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cmd := exec.Command("go", "run", filepath.Join("..", "testdata", "generate.go"),
+		"-players", fmt.Sprint(players), "-sheet", fmt.Sprint(sheet), "-out", dir)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		b.Skipf("generating input: %v\n%s", err, output)
+	}
+
+	fsys := os.DirFS(dir)
+	var input generatedInput
+	if input.players, err = ParseGuildFile(fsys, "guild.txt", GuildOptions{HeaderLines: 1}); err != nil {
+		b.Fatal(err)
+	}
+	if input.sheet, err = ParseSheetFile(fsys, "sheet.txt", CleanOptions{}); err != nil {
+		b.Fatal(err)
+	}
+	if input.altNames, err = ParseAlternativeNamesFile(fsys, "sheet-names.txt"); err != nil {
+		b.Fatal(err)
+	}
+	generatedInputs[size] = input
+	return input
+}
+
+func BenchmarkFindOnlinePlayersNotInSheet(b *testing.B) {
+	input := generate(b, 5000, 4000)
+	matcher := GuildNameMatcher{AltNames: input.altNames}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, _, err := findOnlinePlayersNotInSheet(context.Background(), input.players, input.sheet, matcher, MatchOptions{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFindSheetPlayersNotInGuild(b *testing.B) {
+	input := generate(b, 5000, 4000)
+	matcher := SheetNameMatcher{AltNames: input.altNames}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := findSheetPlayersNotInGuild(context.Background(), input.players, input.sheet, matcher, MatchOptions{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAnalyze(b *testing.B) {
+	for _, size := range []struct{ players, sheet int }{{500, 400}, {5000, 4000}} {
+		b.Run(fmt.Sprintf("players=%d", size.players), func(b *testing.B) {
+			input := generate(b, size.players, size.sheet)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				Analyze(input.players, input.sheet,
+					GuildNameMatcher{AltNames: input.altNames}, SheetNameMatcher{AltNames: input.altNames}, MatchOptions{})
+			}
+		})
+	}
+}
+
+func BenchmarkAnalyzeFuzzy(b *testing.B) {
+	input := generate(b, 500, 400)
+	opts := MatchOptions{FuzzyRatio: 0.2}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Analyze(input.players, input.sheet,
+			GuildNameMatcher{AltNames: input.altNames, Opts: opts}, SheetNameMatcher{AltNames: input.altNames, Opts: opts}, opts)
+	}
+}
@@ -0,0 +1,322 @@
+package signup
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// nameCleaner is a normalization step that rewrites part of a sheet name
+type nameCleaner struct {
+	name        string
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+// nameCleaners are compiled once and applied in order by CleanPlayerName;
+// each can be turned off by name with -disable-cleaners
+var nameCleaners = []nameCleaner{
+	// Numbered list prefixes (e.g., "1. Xandor", "2) Xandor"); a bare "3." cleans to nothing
+	{name: "list-number", pattern: regexp.MustCompile(`^\d+[.)]\s*`)},
+	// Content in parentheses (e.g., "(realm)", "(Longbow)")
+	{name: "parens", pattern: regexp.MustCompile(`\s*\([^)]*\)\s*`)},
+	// Discord mentions (e.g., "@Xandor")
+	{name: "mention", pattern: regexp.MustCompile(`^@+`)},
+	// Discord discriminators (e.g., "Xandor#1234")
+	{name: "discriminator", pattern: regexp.MustCompile(`#\d{4}$`)},
+}
+
+// numberSuffixPattern matches a trailing season or server number behind a separator, like "Player_S9"
+var numberSuffixPattern = regexp.MustCompile(`^(.*\S)[\s_.-]+([sS]?\d+)$`)
+
+// clanTagPattern matches a leading clan tag like "[KOS] " and captures the tag
+var clanTagPattern = regexp.MustCompile(`^\s*(\[[^\]]*\])\s*`)
+
+// parensNamePattern captures the content of the first parentheses for -name-in-parens
+var parensNamePattern = regexp.MustCompile(`\(([^)]*)\)`)
+
+// quoteReplacer straightens the curly quotes and apostrophes that names pick up when copied
+// from a document, like "D’Artagnan", since the game only uses the ASCII ones
+var quoteReplacer = strings.NewReplacer(
+	"\u2018", "'", "\u2019", "'", "\u201A", "'", "\u201B", "'", "\u2032", "'",
+	"\u201C", "\"", "\u201D", "\"", "\u201E", "\"", "\u201F", "\"", "\u2033", "\"",
+)
+
+// foldName returns the form of a name that guild and sheet names are compared in:
+// lowercase, with curly quotes made straight
+func foldName(name string) string {
+	return strings.ToLower(quoteReplacer.Replace(name))
+}
+
+// matchKey returns the form a name is compared in: folded with foldName, or with only the
+// quotes straightened when case matters, for guilds with accounts like XANDOR and Xandor
+func matchKey(name string, caseSensitive bool) string {
+	if caseSensitive {
+		return quoteReplacer.Replace(name)
+	}
+	return foldName(name)
+}
+
+// squashName returns a name's matchKey without whitespace and underscores, so manual entries
+// like "Xan dor" and "Xan_dor" compare equal to "Xandor"; in-game names can't contain spaces
+func squashName(name string, caseSensitive bool) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || r == '_' {
+			return -1
+		}
+		return r
+	}, matchKey(name, caseSensitive))
+}
+
+// CleanerNames returns the names of the optional sheet name cleaners, for turning them off
+func CleanerNames() []string {
+	var names []string
+	for _, cleaner := range nameCleaners {
+		names = append(names, cleaner.name)
+	}
+	return names
+}
+
+// cleanStep is the form of a name after one named normalization step
+type cleanStep struct {
+	step  string
+	value string
+}
+
+// cleanStepOrder lists the normalization steps in the order they are applied,
+// ending with the case folding done when names are compared
+func cleanStepOrder() []string {
+	order := []string{"original", "name-in-parens", "strip-tags"}
+	for _, cleaner := range nameCleaners {
+		order = append(order, cleaner.name)
+	}
+	return append(order, "strip-symbols", "whitespace", "strip-chars", "strip-numbers", "case")
+}
+
+// cleanPlayerNameSteps applies the normalization steps of CleanPlayerName to a name,
+// recording each step that changed it so collisions can be explained
+func cleanPlayerNameSteps(name string, opts CleanOptions) []cleanStep {
+	steps := []cleanStep{{step: "original", value: name}}
+	cleaned := name
+	apply := func(step string, value string) {
+		if value != cleaned {
+			cleaned = value
+			steps = append(steps, cleanStep{step: step, value: value})
+		}
+	}
+
+	// Some sheets put the in-game name in parentheses instead, so keep that and drop the rest
+	if opts.NameInParens {
+		if parens := parensNamePattern.FindStringSubmatch(name); parens != nil {
+			apply("name-in-parens", parens[1])
+		}
+	}
+
+	// Only a tag at the very start is removed, so brackets later in a name stay
+	if opts.StripTags {
+		if tag := clanTagPattern.FindString(cleaned); tag != "" && tag != cleaned {
+			apply("strip-tags", strings.TrimPrefix(cleaned, tag))
+		}
+	}
+
+	for _, cleaner := range nameCleaners {
+		if !opts.DisabledCleaners[cleaner.name] {
+			apply(cleaner.name, cleaner.pattern.ReplaceAllString(cleaned, cleaner.replacement))
+		}
+	}
+
+	// Drop emoji and decorations from Discord-sourced entries, rune by rune so multibyte emoji go whole
+	if opts.StripSymbols {
+		apply("strip-symbols", strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.IsSpace(r) {
+				return r
+			}
+			return -1
+		}, cleaned))
+	}
+
+	// Remove extra whitespace
+	apply("whitespace", strings.TrimSpace(cleaned))
+
+	// Chop a fixed number of characters, working on runes so multibyte glyphs stay intact
+	if opts.StripPrefixChars > 0 || opts.StripSuffixChars > 0 {
+		runes := []rune(cleaned)
+		if opts.StripPrefixChars+opts.StripSuffixChars >= len(runes) {
+			apply("strip-chars", "")
+		} else {
+			apply("strip-chars", strings.TrimSpace(string(runes[opts.StripPrefixChars:len(runes)-opts.StripSuffixChars])))
+		}
+	}
+
+	// Remove season/server numbers; the pattern keeps at least one character of the name
+	if opts.StripNumbers {
+		if parts := numberSuffixPattern.FindStringSubmatch(cleaned); parts != nil {
+			DebugLog.Printf("Stripped suffix '%s' from sheet entry '%s'", strings.TrimPrefix(cleaned, parts[1]), name)
+			apply("strip-numbers", parts[1])
+		}
+	}
+
+	return steps
+}
+
+// CleanPlayerName removes parentheses content and normalizes the name
+func CleanPlayerName(name string, opts CleanOptions) string {
+	steps := cleanPlayerNameSteps(name, opts)
+	cleaned := steps[len(steps)-1].value
+
+	// Remember the tag so a match can say it relied on removing it
+	if opts.StrippedTags != nil {
+		for i, step := range steps {
+			if step.step == "strip-tags" {
+				opts.StrippedTags[cleaned] = clanTagPattern.FindStringSubmatch(steps[i-1].value)[1]
+			}
+		}
+	}
+
+	// Likewise the suffix, so officers can see what -strip-numbers removed
+	if opts.StrippedSuffixes != nil {
+		for i, step := range steps {
+			if step.step == "strip-numbers" {
+				opts.StrippedSuffixes[cleaned] = strings.TrimPrefix(steps[i-1].value, step.value)
+			}
+		}
+	}
+
+	// Keep what the parens cleaner removed, usually the weapon the player was assigned
+	if opts.Loadouts != nil {
+		for i, step := range steps {
+			if step.step == "parens" {
+				var notes []string
+				for _, parens := range parensNamePattern.FindAllStringSubmatch(steps[i-1].value, -1) {
+					if note := strings.TrimSpace(parens[1]); note != "" {
+						notes = append(notes, note)
+					}
+				}
+				if len(notes) > 0 {
+					opts.Loadouts[cleaned] = strings.Join(notes, ", ")
+				}
+			}
+		}
+	}
+
+	// Skip placeholder rows, matched exactly so real short names aren't caught
+	if opts.Placeholders[strings.ToLower(cleaned)] {
+		return ""
+	}
+
+	// Skip obviously invalid entries
+	if isBlocked(cleaned, opts.Blocklist) {
+		return ""
+	}
+
+	return cleaned
+}
+
+// isBlocked reports whether a name contains any blocklist entry, case-insensitively
+func isBlocked(name string, blocklist []BlockedWord) bool {
+	nameLower := strings.ToLower(name)
+	words := strings.FieldsFunc(nameLower, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	for _, blocked := range blocklist {
+		if !blocked.WholeWord {
+			if strings.Contains(nameLower, blocked.Word) {
+				return true
+			}
+			continue
+		}
+		for _, word := range words {
+			if word == blocked.Word {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// NormalizationCollision is a pair of different names that normalization makes equal
+type NormalizationCollision struct {
+	Scope  string // "guild", "sheet" or "across"
+	First  string
+	Second string
+	Step   string // the normalization step after which the names are equal
+}
+
+// FindNormalizationCollisions finds names within the guild, within the sheet and across both
+// that only become equal after normalization, and the step that made them equal
+func FindNormalizationCollisions(guildNames []string, sheetLines []string, opts CleanOptions) []NormalizationCollision {
+	type normalizedName struct {
+		source string
+		forms  []string // the name after each step of cleanStepOrder
+	}
+
+	order := cleanStepOrder()
+	formsOf := func(steps []cleanStep) []string {
+		forms := make([]string, len(order))
+		value := steps[0].value
+		for i, step := range order {
+			for _, applied := range steps {
+				if applied.step == step {
+					value = applied.value
+				}
+			}
+			if step == "case" {
+				value = foldName(value)
+			}
+			forms[i] = value
+		}
+		return forms
+	}
+
+	// Group names by their fully normalized form, since only those can collide
+	groups := make(map[string][]normalizedName)
+	var keys []string
+	add := func(source string, steps []cleanStep) {
+		name := normalizedName{source: source, forms: formsOf(steps)}
+		key := name.forms[len(name.forms)-1]
+		if _, exists := groups[key]; !exists {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], name)
+	}
+
+	for _, guildName := range guildNames {
+		add("guild", []cleanStep{{step: "original", value: guildName}})
+	}
+	for _, line := range sheetLines {
+		if CleanPlayerName(line, opts) != "" {
+			add("sheet", cleanPlayerNameSteps(line, opts))
+		}
+	}
+
+	var collisions []NormalizationCollision
+	for _, key := range keys {
+		names := groups[key]
+		for i := 0; i < len(names); i++ {
+			for j := i + 1; j < len(names); j++ {
+				a, b := names[i], names[j]
+				if a.forms[0] == b.forms[0] {
+					continue
+				}
+
+				scope := a.source
+				if a.source != b.source {
+					scope = "across"
+				}
+				for k := range order {
+					if a.forms[k] == b.forms[k] {
+						collisions = append(collisions, NormalizationCollision{
+							Scope:  scope,
+							First:  a.source + " '" + a.forms[0] + "'",
+							Second: b.source + " '" + b.forms[0] + "'",
+							Step:   order[k],
+						})
+						break
+					}
+				}
+			}
+		}
+	}
+
+	return collisions
+}
@@ -0,0 +1,89 @@
+package signup
+
+import "testing"
+
+func TestFoldName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Xandor", "xandor"},
+		{"D’Artagnan", "d'artagnan"},
+		{"D‘Artagnan", "d'artagnan"},
+		{"D'Artagnan", "d'artagnan"},
+		{"“Bone”", "\"bone\""},
+	}
+	for _, tt := range tests {
+		if got := foldName(tt.name); got != tt.want {
+			t.Errorf("foldName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFindNameMatchCurlyQuotes(t *testing.T) {
+	tests := []struct {
+		guildName string
+		sheetName string
+	}{
+		{"D'Artagnan", "D’Artagnan"},
+		{"D’Artagnan", "d'artagnan"},
+		{"O'Neil", "O′Neil"},
+	}
+	for _, tt := range tests {
+		got := FindNameMatch(tt.guildName, []string{tt.sheetName}, NewAlternativeNames(), nil, MatchOptions{})
+		if !got.Found || got.MatchType != "direct" {
+			t.Errorf("FindNameMatch(%q, %q) = found %v, type %q; want a direct match", tt.guildName, tt.sheetName, got.Found, got.MatchType)
+		}
+	}
+}
+
+func TestStripNumbers(t *testing.T) {
+	tests := []struct {
+		name       string
+		wantName   string
+		wantSuffix string
+	}{
+		{"Player_S9", "Player", "_S9"},
+		{"Player-2", "Player", "-2"},
+		{"Player s12", "Player", " s12"},
+		{"Player.3", "Player", ".3"},
+		// Digits that are part of the name have no separator in front
+		{"Player2", "Player2", ""},
+		// A name is never reduced to nothing, so a bare number or separator stays
+		{"_S9", "_S9", ""},
+		{"42", "42", ""},
+		{"X_9", "X", "_9"},
+	}
+	for _, tt := range tests {
+		suffixes := make(map[string]string)
+		got := CleanPlayerName(tt.name, CleanOptions{StripNumbers: true, StrippedSuffixes: suffixes})
+		if got != tt.wantName {
+			t.Errorf("CleanPlayerName(%q) = %q, want %q", tt.name, got, tt.wantName)
+		}
+		if suffix := suffixes[got]; suffix != tt.wantSuffix {
+			t.Errorf("CleanPlayerName(%q) recorded suffix %q, want %q", tt.name, suffix, tt.wantSuffix)
+		}
+	}
+}
+
+func TestStrippedSuffixReachesMatch(t *testing.T) {
+	cleanOpts := CleanOptions{StripNumbers: true, StrippedSuffixes: make(map[string]string)}
+	sheet := []string{CleanPlayerName("Xandor_S9", cleanOpts)}
+	opts := MatchOptions{SheetSuffixes: cleanOpts.StrippedSuffixes}
+	result := Analyze([]Player{{Username: "Xandor", Status: "Online"}}, sheet,
+		GuildNameMatcher{AltNames: NewAlternativeNames(), Opts: opts}, SheetNameMatcher{AltNames: NewAlternativeNames(), Opts: opts}, opts)
+	if len(result.GuildMatches) != 1 || result.GuildMatches[0].StrippedSuffix != "_S9" {
+		t.Errorf("guild matches = %+v, want one with stripped suffix _S9", result.GuildMatches)
+	}
+	if len(result.SheetMatches) != 1 || result.SheetMatches[0].StrippedSuffix != "_S9" {
+		t.Errorf("sheet matches = %+v, want one with stripped suffix _S9", result.SheetMatches)
+	}
+}
+
+func TestFindNormalizationCollisionsCurlyQuotes(t *testing.T) {
+	got := FindNormalizationCollisions([]string{"D'Artagnan"}, []string{"D’Artagnan"}, CleanOptions{})
+	want := NormalizationCollision{Scope: "across", First: "guild 'D'Artagnan'", Second: "sheet 'D’Artagnan'", Step: "case"}
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("FindNormalizationCollisions() = %+v, want [%+v]", got, want)
+	}
+}
@@ -0,0 +1,34 @@
+package signup_test
+
+import (
+	"fmt"
+	"strings"
+
+	"signup-checker/signup"
+)
+
+func ExampleFindNameMatch() {
+	sheet, err := signup.ParseSheet(strings.NewReader("Xandor (Longbow)\nPX\n"), signup.CleanOptions{})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	altNames, err := signup.ParseAlternativeNames(strings.NewReader("PlayerX: PX\n"))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	for _, name := range []string{"Xandor", "PlayerX", "Bonehic"} {
+		match := signup.FindNameMatch(name, sheet, altNames, nil, signup.MatchOptions{})
+		if !match.Found {
+			fmt.Printf("%s: not signed up\n", name)
+			continue
+		}
+		fmt.Printf("%s: found %s\n", name, signup.DescribeMatch(match))
+	}
+	// Output:
+	// Xandor: found directly as 'Xandor'
+	// PlayerX: found via alternative 'PX'
+	// Bonehic: not signed up
+}
@@ -0,0 +1,431 @@
+package signup
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// FuzzyAlgorithm scores how close two names are for the fuzzy fallback of matching, so other
+// similarity measures can be plugged in next to Levenshtein
+type FuzzyAlgorithm interface {
+	// Compare scores a sheet name against a guild name, both already in their matchKey form;
+	// lower scores are closer, and ok reports whether the names are close enough to match
+	Compare(guildName, sheetName string) (score float64, ok bool)
+	// Describe fills in the confidence and method of a fuzzy match with the given score
+	Describe(result *MatchResult, score float64)
+}
+
+// FuzzyAlgorithmNames are the fuzzy algorithms that can be chosen by name
+var FuzzyAlgorithmNames = []string{"levenshtein", "damerau", "jaro-winkler", "trigram", "metaphone"}
+
+// LevenshteinFuzzy matches names within a number of edits
+type LevenshteinFuzzy struct {
+	Ratio    float64 // allowed edits as a fraction of the guild name length
+	Distance int     // allowed edits regardless of length, takes precedence over Ratio
+}
+
+// maxDistance returns how many edits a fuzzy match against guildName may need
+func (l LevenshteinFuzzy) maxDistance(guildName string) int {
+	if l.Distance > 0 {
+		return l.Distance
+	}
+	if l.Ratio <= 0 {
+		return 0
+	}
+	// Rounding down means short names like "Bo" need an exact match unless Distance is set
+	return int(math.Floor(float64(utf8.RuneCountInString(guildName)) * l.Ratio))
+}
+
+// Compare returns the edit distance between the names
+func (l LevenshteinFuzzy) Compare(guildName, sheetName string) (float64, bool) {
+	distance := Levenshtein(guildName, sheetName)
+	return float64(distance), distance <= l.maxDistance(guildName)
+}
+
+// Describe records the edit distance, with the confidence falling by edit relative to the name length
+func (l LevenshteinFuzzy) Describe(result *MatchResult, score float64) {
+	result.Distance = int(score)
+	result.Confidence = 1 - score/float64(max(utf8.RuneCountInString(result.GuildName), 1))
+	result.Method = fmt.Sprintf("levenshtein distance %d", result.Distance)
+}
+
+// DamerauFuzzy matches names within a number of edits like LevenshteinFuzzy, but counts swapping
+// two adjacent letters as one edit, so the common typo "Xadnor" is one edit from "Xandor"
+type DamerauFuzzy struct {
+	LevenshteinFuzzy
+}
+
+// Compare returns the Damerau-Levenshtein distance between the names
+func (d DamerauFuzzy) Compare(guildName, sheetName string) (float64, bool) {
+	distance := DamerauLevenshtein(guildName, sheetName)
+	return float64(distance), distance <= d.maxDistance(guildName)
+}
+
+// Describe records the edit distance like LevenshteinFuzzy
+func (d DamerauFuzzy) Describe(result *MatchResult, score float64) {
+	d.LevenshteinFuzzy.Describe(result, score)
+	result.Method = fmt.Sprintf("damerau-levenshtein distance %d", result.Distance)
+}
+
+// JaroWinklerFuzzy matches names whose Jaro-Winkler similarity reaches a threshold; it penalizes
+// swapped letters less than Levenshtein, which suits short in-game names
+type JaroWinklerFuzzy struct {
+	Threshold float64 // lowest similarity in [0,1] that counts as a match
+}
+
+// Compare returns one minus the similarity of the names
+func (j JaroWinklerFuzzy) Compare(guildName, sheetName string) (float64, bool) {
+	similarity := JaroWinkler(guildName, sheetName)
+	return 1 - similarity, similarity >= j.Threshold
+}
+
+// Describe records the similarity, which is also the confidence
+func (j JaroWinklerFuzzy) Describe(result *MatchResult, score float64) {
+	result.Similarity = 1 - score
+	result.Confidence = result.Similarity
+	result.Method = fmt.Sprintf("jaro-winkler similarity %.2f", result.Similarity)
+}
+
+// TrigramFuzzy matches names whose sets of three-rune sequences overlap enough; inserted or
+// dropped letters only change the trigrams around them, whatever the name length
+type TrigramFuzzy struct {
+	Threshold float64 // lowest Jaccard similarity of the trigrams in [0,1] that counts as a match
+}
+
+// Compare returns one minus the trigram similarity of the names
+func (t TrigramFuzzy) Compare(guildName, sheetName string) (float64, bool) {
+	similarity := TrigramSimilarity(guildName, sheetName)
+	return 1 - similarity, similarity >= t.Threshold
+}
+
+// Describe records the similarity, which is also the confidence
+func (t TrigramFuzzy) Describe(result *MatchResult, score float64) {
+	result.Similarity = 1 - score
+	result.Confidence = result.Similarity
+	result.Method = fmt.Sprintf("trigram similarity %.2f", result.Similarity)
+}
+
+// MetaphoneFuzzy matches names that sound alike, like Kathryn and Catherine, by their Metaphone
+// keys. The rules are those of English spelling, so names from other languages can collide or
+// miss; matches get the "phonetic" match type to keep them apart from spelling-based ones.
+type MetaphoneFuzzy struct{}
+
+// Compare reports names with the same phonetic key as a match with score 0
+func (MetaphoneFuzzy) Compare(guildName, sheetName string) (float64, bool) {
+	key := PhoneticKey(guildName)
+	return 0, key != "" && key == PhoneticKey(sheetName)
+}
+
+// Describe marks the match as phonetic and records the shared key
+func (MetaphoneFuzzy) Describe(result *MatchResult, score float64) {
+	result.MatchType = "phonetic"
+	result.Confidence = 0.6
+	result.Method = fmt.Sprintf("same Metaphone key '%s'", PhoneticKey(result.GuildName))
+}
+
+// NewFuzzyAlgorithm returns the fuzzy algorithm with the given name, using the edit limits
+// for Levenshtein and the threshold for Jaro-Winkler
+func NewFuzzyAlgorithm(name string, ratio float64, distance int, threshold float64) (FuzzyAlgorithm, error) {
+	switch name {
+	case "levenshtein":
+		return LevenshteinFuzzy{Ratio: ratio, Distance: distance}, nil
+	case "damerau":
+		if ratio <= 0 && distance <= 0 {
+			return nil, fmt.Errorf("damerau needs an edit distance or ratio to match within")
+		}
+		return DamerauFuzzy{LevenshteinFuzzy{Ratio: ratio, Distance: distance}}, nil
+	case "jaro-winkler":
+		if threshold <= 0 || threshold > 1 {
+			return nil, fmt.Errorf("jaro-winkler threshold must be in (0,1], got %v", threshold)
+		}
+		return JaroWinklerFuzzy{Threshold: threshold}, nil
+	case "trigram":
+		if threshold <= 0 || threshold > 1 {
+			return nil, fmt.Errorf("trigram threshold must be in (0,1], got %v", threshold)
+		}
+		return TrigramFuzzy{Threshold: threshold}, nil
+	case "metaphone":
+		return MetaphoneFuzzy{}, nil
+	}
+	return nil, fmt.Errorf("unknown fuzzy algorithm %q, expected one of %s", name, strings.Join(FuzzyAlgorithmNames, ", "))
+}
+
+// fuzzyAlgorithm returns the fuzzy algorithm to use, or nil when fuzzy matching is off;
+// without one set, -fuzzy and -fuzzy-ratio select Levenshtein
+func fuzzyAlgorithm(opts MatchOptions) FuzzyAlgorithm {
+	if opts.FuzzyAlgorithm != nil {
+		return opts.FuzzyAlgorithm
+	}
+	if opts.FuzzyRatio > 0 || opts.FuzzyDistance > 0 {
+		return LevenshteinFuzzy{Ratio: opts.FuzzyRatio, Distance: opts.FuzzyDistance}
+	}
+	return nil
+}
+
+// DamerauLevenshtein returns the edit distance between two strings, counted in runes, where
+// swapping two adjacent runes is one edit; each substring is edited at most once, so "ca" to
+// "abc" takes three edits rather than two
+func DamerauLevenshtein(a, b string) int {
+	ra := []rune(a)
+	rb := []rune(b)
+
+	// A transposition looks two rows back, so keep three rows of the distance matrix
+	prevPrev := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				curr[j] = min(curr[j], prevPrev[j-2]+1)
+			}
+		}
+		prevPrev, prev, curr = prev, curr, prevPrev
+	}
+
+	return prev[len(rb)]
+}
+
+// trigrams returns the set of three-rune sequences of a name padded with two spaces in front
+// and one behind, so even one- and two-rune names have some and the ends weigh more
+func trigrams(name string) map[string]bool {
+	runes := []rune("  " + name + " ")
+	set := make(map[string]bool, len(runes))
+	for i := 0; i+3 <= len(runes); i++ {
+		set[string(runes[i:i+3])] = true
+	}
+	return set
+}
+
+// TrigramSimilarity returns the Jaccard similarity of the trigrams of two strings in [0,1]:
+// the trigrams they share over all the trigrams either has, where 1 means the same set
+func TrigramSimilarity(a, b string) float64 {
+	ta := trigrams(a)
+	tb := trigrams(b)
+	shared := 0
+	for trigram := range ta {
+		if tb[trigram] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(ta)+len(tb)-shared)
+}
+
+// JaroWinkler returns the Jaro-Winkler similarity of two strings in [0,1], counted in runes,
+// where 1 means equal; a shared prefix of up to four runes raises the score
+func JaroWinkler(a, b string) float64 {
+	ra := []rune(a)
+	rb := []rune(b)
+	if len(ra) == 0 && len(rb) == 0 {
+		return 1
+	}
+	if len(ra) == 0 || len(rb) == 0 {
+		return 0
+	}
+
+	// Runes only count as matching within this distance of each other
+	window := max(max(len(ra), len(rb))/2-1, 0)
+	matchedA := make([]bool, len(ra))
+	matchedB := make([]bool, len(rb))
+	matches := 0
+	for i := range ra {
+		for j := max(0, i-window); j < min(len(rb), i+window+1); j++ {
+			if !matchedB[j] && ra[i] == rb[j] {
+				matchedA[i], matchedB[j] = true, true
+				matches++
+				break
+			}
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+
+	// Half the matching runes that are out of order are transpositions
+	transpositions := 0
+	j := 0
+	for i := range ra {
+		if !matchedA[i] {
+			continue
+		}
+		for !matchedB[j] {
+			j++
+		}
+		if ra[i] != rb[j] {
+			transpositions++
+		}
+		j++
+	}
+
+	m := float64(matches)
+	jaro := (m/float64(len(ra)) + m/float64(len(rb)) + (m-float64(transpositions/2))/m) / 3
+
+	prefix := 0
+	for prefix < min(4, len(ra), len(rb)) && ra[prefix] == rb[prefix] {
+		prefix++
+	}
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
+
+// PhoneticKey returns the Metaphone key of a name's letters followed by its digits, so that
+// "Bob1" and "Bob2" stay different players; names without letters have no key
+func PhoneticKey(name string) string {
+	var letters []rune
+	var digits strings.Builder
+	for _, r := range strings.ToUpper(name) {
+		switch {
+		case r >= 'A' && r <= 'Z':
+			letters = append(letters, r)
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		}
+	}
+	if len(letters) == 0 {
+		return ""
+	}
+	return Metaphone(string(letters)) + digits.String()
+}
+
+// Metaphone returns Lawrence Philips' original Metaphone key of an English word of the letters
+// A to Z, like "K0RN" for both KATHRYN and CATHERINE; 0 stands for "th" and X for "sh"
+func Metaphone(word string) string {
+	w := []rune(strings.ToUpper(word))
+	at := func(i int) rune {
+		if i < 0 || i >= len(w) {
+			return 0
+		}
+		return w[i]
+	}
+	isVowel := func(r rune) bool { return strings.ContainsRune("AEIOU", r) }
+	isFrontVowel := func(r rune) bool { return r == 'E' || r == 'I' || r == 'Y' }
+
+	// Some leading letter pairs are written but not spoken
+	start := 0
+	switch {
+	case len(w) >= 2 && strings.Contains("AE GN KN PN WR", string(w[:2])):
+		start = 1
+	case at(0) == 'X':
+		w[0] = 'S'
+	case at(0) == 'W' && at(1) == 'H':
+		w = append(w[:1], w[2:]...)
+	}
+
+	var key strings.Builder
+	for i := start; i < len(w); i++ {
+		c := w[i]
+		// Doubled letters sound once, except CC as in "accent"
+		if c == at(i-1) && c != 'C' {
+			continue
+		}
+		switch c {
+		case 'A', 'E', 'I', 'O', 'U':
+			if i == start {
+				key.WriteRune(c)
+			}
+		case 'B':
+			if !(at(i-1) == 'M' && i == len(w)-1) {
+				key.WriteRune('B')
+			}
+		case 'C':
+			switch {
+			case at(i+1) == 'I' && at(i+2) == 'A':
+				key.WriteRune('X')
+			case at(i+1) == 'H':
+				if at(i-1) == 'S' {
+					key.WriteRune('K')
+				} else {
+					key.WriteRune('X')
+				}
+				i++
+			case isFrontVowel(at(i + 1)):
+				if at(i-1) != 'S' {
+					key.WriteRune('S')
+				}
+			default:
+				key.WriteRune('K')
+			}
+		case 'D':
+			if at(i+1) == 'G' && isFrontVowel(at(i+2)) {
+				key.WriteRune('J')
+				i++
+			} else {
+				key.WriteRune('T')
+			}
+		case 'G':
+			switch {
+			case at(i+1) == 'H' && i+2 < len(w) && !isVowel(at(i+2)):
+				// Silent as in "night"
+			case at(i+1) == 'N' && (i+2 == len(w) || (at(i+2) == 'E' && at(i+3) == 'D' && i+4 == len(w))):
+				// Silent as in "sign" and "signed"
+			case isFrontVowel(at(i+1)) && at(i-1) != 'G':
+				key.WriteRune('J')
+			default:
+				key.WriteRune('K')
+			}
+		case 'H':
+			if isVowel(at(i+1)) && !strings.ContainsRune("CGPST", at(i-1)) {
+				key.WriteRune('H')
+			}
+		case 'K':
+			if at(i-1) != 'C' {
+				key.WriteRune('K')
+			}
+		case 'P':
+			if at(i+1) == 'H' {
+				key.WriteRune('F')
+				i++
+			} else {
+				key.WriteRune('P')
+			}
+		case 'Q':
+			key.WriteRune('K')
+		case 'S':
+			switch {
+			case at(i+1) == 'H':
+				key.WriteRune('X')
+				i++
+			case at(i+1) == 'I' && (at(i+2) == 'O' || at(i+2) == 'A'):
+				key.WriteRune('X')
+			default:
+				key.WriteRune('S')
+			}
+		case 'T':
+			switch {
+			case at(i+1) == 'I' && (at(i+2) == 'O' || at(i+2) == 'A'):
+				key.WriteRune('X')
+			case at(i+1) == 'H':
+				key.WriteRune('0')
+				i++
+			case at(i+1) == 'C' && at(i+2) == 'H':
+				// Silent as in "watch", the CH that follows is an X
+			default:
+				key.WriteRune('T')
+			}
+		case 'V':
+			key.WriteRune('F')
+		case 'W', 'Y':
+			if isVowel(at(i + 1)) {
+				key.WriteRune(c)
+			}
+		case 'X':
+			key.WriteString("KS")
+		case 'Z':
+			key.WriteRune('S')
+		default:
+			// F, J, L, M, N and R sound as written
+			key.WriteRune(c)
+		}
+	}
+	return key.String()
+}
@@ -0,0 +1,167 @@
+package signup
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDamerauLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b        string
+		want        int
+		levenshtein int
+	}{
+		{"xandor", "xandor", 0, 0},
+		{"xandor", "xadnor", 1, 2},
+		{"xandor", "axndor", 1, 2},
+		{"xandor", "xandro", 1, 2},
+		{"xandor", "axndro", 2, 4},
+		{"xandor", "xandr", 1, 1},
+		{"xandor", "", 6, 6},
+		// Each substring is edited once, so this is not a transposition plus an insertion
+		{"ca", "abc", 3, 3},
+		{"ab", "ba", 1, 2},
+		{"ñá", "áñ", 1, 2},
+	}
+	for _, tt := range tests {
+		if got := DamerauLevenshtein(tt.a, tt.b); got != tt.want {
+			t.Errorf("DamerauLevenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := DamerauLevenshtein(tt.b, tt.a); got != tt.want {
+			t.Errorf("DamerauLevenshtein(%q, %q) = %d, want %d", tt.b, tt.a, got, tt.want)
+		}
+		if got := Levenshtein(tt.a, tt.b); got != tt.levenshtein {
+			t.Errorf("Levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.levenshtein)
+		}
+	}
+}
+
+func TestDamerauFuzzyMatchesTransposition(t *testing.T) {
+	opts := MatchOptions{FuzzyAlgorithm: DamerauFuzzy{LevenshteinFuzzy{Distance: 1}}}
+	got := FindNameMatch("Xandor", []string{"Xadnor"}, NewAlternativeNames(), nil, opts)
+	if !got.Found || got.MatchType != "fuzzy" || got.Distance != 1 {
+		t.Errorf("FindNameMatch(Xandor, Xadnor) = %+v, want a fuzzy match at distance 1", got)
+	}
+
+	opts.FuzzyAlgorithm = LevenshteinFuzzy{Distance: 1}
+	if got := FindNameMatch("Xandor", []string{"Xadnor"}, NewAlternativeNames(), nil, opts); got.Found {
+		t.Errorf("FindNameMatch(Xandor, Xadnor) with Levenshtein distance 1 = %+v, want no match", got)
+	}
+}
+
+func TestLevenshteinFuzzyRatio(t *testing.T) {
+	// The allowed edits round down, so "Bo" needs an exact match while a long name allows a couple
+	fuzzy := LevenshteinFuzzy{Ratio: 0.1}
+	tests := []struct {
+		name string
+		want int
+	}{
+		{"Bo", 0},
+		{"Xandor", 0},
+		{"Xandorthegreat", 1},
+		{"Alexandertheconqueror", 2},
+	}
+	for _, tt := range tests {
+		if got := fuzzy.maxDistance(tt.name); got != tt.want {
+			t.Errorf("maxDistance(%q) = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+	if got := (LevenshteinFuzzy{Ratio: 0.1, Distance: 1}).maxDistance("Bo"); got != 1 {
+		t.Errorf("maxDistance(Bo) with Distance 1 = %d, want 1", got)
+	}
+
+	opts := MatchOptions{FuzzyAlgorithm: fuzzy}
+	if got := FindNameMatch("Alexandertheconqueror", []string{"Alexandrtheconquerer"}, NewAlternativeNames(), nil, opts); !got.Found || got.Distance != 2 {
+		t.Errorf("FindNameMatch(Alexandertheconqueror, Alexandrtheconquerer) = %+v, want a fuzzy match at distance 2", got)
+	}
+	if got := FindNameMatch("Bo", []string{"Bi"}, NewAlternativeNames(), nil, opts); got.Found {
+		t.Errorf("FindNameMatch(Bo, Bi) = %+v, want no match", got)
+	}
+}
+
+func TestTrigramSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"xandor", "xandor", 1},
+		{"xandor", "bonehic", 0},
+		{"", "", 1},
+		// "  x", " xa", "xan", "and", "ndo", "dor", "or " against "ndor" shares "ndo", "dor", "or "
+		{"xandor", "ndor", 3.0 / 9.0},
+	}
+	for _, tt := range tests {
+		if got := TrigramSimilarity(tt.a, tt.b); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("TrigramSimilarity(%q, %q) = %.3f, want %.3f", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+// Trigrams tolerate letters added to a long name, which Levenshtein only allows with a ratio
+// loose enough to also match unrelated short names
+func TestTrigramAgainstLevenshtein(t *testing.T) {
+	tests := []struct {
+		guildName, sheetName string
+		trigram, levenshtein bool
+	}{
+		{"Xandorthebrave", "Xandorthebrave123", true, false},
+		{"Xandor", "Xandr", false, true},
+		{"Bone", "Bono", false, false},
+		{"Xandor", "Xandor", true, true},
+	}
+	trigram := TrigramFuzzy{Threshold: 0.7}
+	levenshtein := LevenshteinFuzzy{Ratio: 0.2}
+	for _, tt := range tests {
+		guildName, sheetName := foldName(tt.guildName), foldName(tt.sheetName)
+		if _, ok := trigram.Compare(guildName, sheetName); ok != tt.trigram {
+			t.Errorf("trigram match of %q and %q = %v, want %v (similarity %.2f)",
+				tt.guildName, tt.sheetName, ok, tt.trigram, TrigramSimilarity(guildName, sheetName))
+		}
+		if _, ok := levenshtein.Compare(guildName, sheetName); ok != tt.levenshtein {
+			t.Errorf("levenshtein match of %q and %q = %v, want %v (distance %d)",
+				tt.guildName, tt.sheetName, ok, tt.levenshtein, Levenshtein(guildName, sheetName))
+		}
+	}
+}
+
+func TestPhoneticKey(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Kathryn", "K0RN"},
+		{"Catherine", "K0RN"},
+		{"Knight", "NT"},
+		{"Bob1", "BB1"},
+		{"1234", ""},
+	}
+	for _, tt := range tests {
+		if got := PhoneticKey(tt.name); got != tt.want {
+			t.Errorf("PhoneticKey(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// Metaphone follows English spelling, so these pairs show both sides of its bias: English
+// sound-alikes match, while names spelled by other rules can collide or be kept apart
+func TestMetaphoneMatches(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"Kathryn", "Catherine", true},
+		{"Stephen", "Steven", true},
+		{"Smith", "Smyth", true},
+		{"Bob1", "Bob2", false},
+		// German "sch" is one sound but English reads it as S and K, unlike "sh"
+		{"Schmidt", "Shmit", false},
+		// Spanish "j" sounds like "h", which English doesn't know
+		{"Jose", "Hose", false},
+	}
+	for _, tt := range tests {
+		if _, got := (MetaphoneFuzzy{}).Compare(tt.a, tt.b); got != tt.want {
+			t.Errorf("metaphone match of %q and %q = %v, want %v (keys %q, %q)",
+				tt.a, tt.b, got, tt.want, PhoneticKey(tt.a), PhoneticKey(tt.b))
+		}
+	}
+}
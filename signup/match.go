@@ -0,0 +1,729 @@
+package signup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// NameIndex holds a list of names keyed for exact lookups, so matching many names against
+// the same list doesn't scan it once per name
+type NameIndex struct {
+	byKey         map[string]string // matchKey of the name -> first name in the list with that key
+	bySquashed    map[string]string // matchKey without whitespace and underscores -> first such name
+	names         map[string]bool   // the names exactly as listed
+	caseSensitive bool              // whether the keys keep the case of the names
+}
+
+// NewNameIndex indexes the names for case-insensitive matching
+func NewNameIndex(names []string) NameIndex {
+	return newNameIndex(names, false)
+}
+
+// newNameIndex indexes the names, keeping their case when caseSensitive is set
+func newNameIndex(names []string, caseSensitive bool) NameIndex {
+	index := NameIndex{
+		byKey:         make(map[string]string, len(names)),
+		bySquashed:    make(map[string]string, len(names)),
+		names:         make(map[string]bool, len(names)),
+		caseSensitive: caseSensitive,
+	}
+	for _, name := range names {
+		key := matchKey(name, caseSensitive)
+		if _, exists := index.byKey[key]; !exists {
+			index.byKey[key] = name
+		}
+		if squashed := squashName(name, caseSensitive); index.bySquashed[squashed] == "" {
+			index.bySquashed[squashed] = name
+		}
+		index.names[name] = true
+	}
+	return index
+}
+
+// FindNameMatch checks if a guild name exists in the sheet names, using alternative names
+func FindNameMatch(guildName string, sheetNames []string, altNames *AlternativeNames, ignoredNames []string, opts MatchOptions) MatchResult {
+	return findNameMatchIndexed(guildName, sheetNames, newNameIndex(sheetNames, opts.CaseSensitive), altNames, ignoredNames, opts)
+}
+
+// findNameMatchIndexed is FindNameMatch with a prebuilt index of the sheet names
+func findNameMatchIndexed(guildName string, sheetNames []string, sheetIndex NameIndex, altNames *AlternativeNames, ignoredNames []string, opts MatchOptions) (result MatchResult) {
+	defer func() { logMatchAttempt("guild", guildName, result, opts) }()
+	if sheetIndex.caseSensitive != opts.CaseSensitive {
+		sheetIndex = newNameIndex(sheetNames, opts.CaseSensitive)
+	}
+	guildNameLower := matchKey(guildName, opts.CaseSensitive)
+
+	// Check direct match first
+	if sheetName, exists := sheetIndex.byKey[guildNameLower]; exists {
+		return directMatchResult(guildName, sheetName, opts)
+	}
+
+	// Manually typed names can have stray spaces or underscores in them
+	if opts.NormalizeWhitespace {
+		if sheetName, exists := sheetIndex.bySquashed[squashName(guildName, opts.CaseSensitive)]; exists {
+			return whitespaceMatchResult(guildName, sheetName, opts)
+		}
+	}
+
+	// Check alternative names
+	if alternatives, exists := altNames.GuildToAlternatives[guildName]; exists {
+		for _, alt := range alternatives {
+			if sheetName, exists := sheetIndex.byKey[matchKey(alt, opts.CaseSensitive)]; exists {
+				return alternativeMatchResult(guildName, alt, sheetName, altNames, opts)
+			}
+		}
+	}
+
+	// Check ignored patterns (legacy support)
+	for _, sheetName := range sheetNames {
+		for _, ignored := range ignoredNames {
+			ignoredLower := matchKey(ignored, opts.CaseSensitive)
+			if strings.Contains(guildNameLower, ignoredLower) && strings.Contains(matchKey(sheetName, opts.CaseSensitive), ignoredLower) {
+				return MatchResult{
+					Found:           true,
+					GuildName:       guildName,
+					AlternativeName: sheetName,
+					MatchType:       "ignored",
+					MatchedName:     sheetName,
+					Confidence:      0.5,
+					Method:          fmt.Sprintf("shared ignored pattern '%s'", ignored),
+				}
+			}
+		}
+	}
+
+	// Display names like "Xandor the Brave" contain the guild name as one of their words
+	if opts.TokenOverlap > 0 {
+		bestName := ""
+		bestFound, bestTotal := 0, 0
+		for _, sheetName := range sheetNames {
+			found, total, ok := tokenMatch(guildName, sheetName, opts)
+			if ok && (bestName == "" || found*bestTotal > bestFound*total) {
+				bestName = sheetName
+				bestFound, bestTotal = found, total
+			}
+		}
+		if bestName != "" {
+			return tokenMatchResult(guildName, bestName, bestName, bestFound, bestTotal)
+		}
+	}
+
+	// Fall back to fuzzy matching to tolerate typos in the sheet
+	if algorithm := fuzzyAlgorithm(opts); algorithm != nil {
+		bestName := ""
+		bestScore := -1.0
+		for _, sheetName := range sheetNames {
+			score, ok := algorithm.Compare(guildNameLower, matchKey(sheetName, opts.CaseSensitive))
+			if ok && (bestScore < 0 || score < bestScore) {
+				bestName = sheetName
+				bestScore = score
+			}
+		}
+		if bestScore >= 0 {
+			return fuzzyMatchResult(guildName, bestName, bestName, algorithm, bestScore)
+		}
+	}
+
+	return MatchResult{Found: false}
+}
+
+// FindSheetNameMatch checks if a sheet name exists in guild names, using alternative names
+func FindSheetNameMatch(sheetName string, guildNames []string, altNames *AlternativeNames, ignoredNames []string, opts MatchOptions) MatchResult {
+	return findSheetNameMatchIndexed(sheetName, guildNames, newNameIndex(guildNames, opts.CaseSensitive), altNames, ignoredNames, opts)
+}
+
+// findSheetNameMatchIndexed is FindSheetNameMatch with a prebuilt index of the guild names
+func findSheetNameMatchIndexed(sheetName string, guildNames []string, guildIndex NameIndex, altNames *AlternativeNames, ignoredNames []string, opts MatchOptions) (result MatchResult) {
+	defer func() { logMatchAttempt("sheet", sheetName, result, opts) }()
+	if guildIndex.caseSensitive != opts.CaseSensitive {
+		guildIndex = newNameIndex(guildNames, opts.CaseSensitive)
+	}
+	sheetNameLower := matchKey(sheetName, opts.CaseSensitive)
+
+	// Check direct match first
+	if guildName, exists := guildIndex.byKey[sheetNameLower]; exists {
+		return directMatchResult(guildName, guildName, opts)
+	}
+
+	// Manually typed names can have stray spaces or underscores in them
+	if opts.NormalizeWhitespace {
+		if guildName, exists := guildIndex.bySquashed[squashName(sheetName, opts.CaseSensitive)]; exists {
+			return whitespaceMatchResult(guildName, guildName, opts)
+		}
+	}
+
+	// Check if sheet name is an alternative name, of a guild name that actually exists in the guild list
+	alternativeToGuild := altNames.AlternativeToGuild
+	if opts.CaseSensitive {
+		alternativeToGuild = altNames.AlternativeToGuildExact
+	}
+	if guildName, exists := alternativeToGuild[sheetNameLower]; exists && guildIndex.names[guildName] {
+		return alternativeMatchResult(guildName, sheetName, guildName, altNames, opts)
+	}
+
+	// Check ignored patterns (legacy support)
+	var patternCandidates []string
+	matchedPattern := ""
+	for _, guildName := range guildNames {
+		for _, ignored := range ignoredNames {
+			ignoredLower := matchKey(ignored, opts.CaseSensitive)
+			if strings.Contains(sheetNameLower, ignoredLower) && strings.Contains(matchKey(guildName, opts.CaseSensitive), ignoredLower) {
+				patternCandidates = append(patternCandidates, guildName)
+				matchedPattern = ignored
+				break
+			}
+		}
+	}
+	if len(patternCandidates) == 1 {
+		return MatchResult{
+			Found:           true,
+			GuildName:       patternCandidates[0],
+			AlternativeName: sheetName,
+			MatchType:       "ignored",
+			MatchedName:     patternCandidates[0],
+			Confidence:      0.5,
+			Method:          fmt.Sprintf("shared ignored pattern '%s'", matchedPattern),
+		}
+	}
+	if len(patternCandidates) > 1 {
+		return ambiguousMatchResult(sheetName, patternCandidates)
+	}
+
+	// Display names like "Xandor the Brave" contain the guild name as one of their words
+	if opts.TokenOverlap > 0 {
+		var tokenCandidates []string
+		bestFound, bestTotal := 0, 0
+		for _, guildName := range guildNames {
+			found, total, ok := tokenMatch(guildName, sheetName, opts)
+			if ok {
+				tokenCandidates = append(tokenCandidates, guildName)
+				bestFound, bestTotal = found, total
+			}
+		}
+		if len(tokenCandidates) == 1 {
+			return tokenMatchResult(tokenCandidates[0], sheetName, tokenCandidates[0], bestFound, bestTotal)
+		}
+		if len(tokenCandidates) > 1 {
+			return ambiguousMatchResult(sheetName, tokenCandidates)
+		}
+	}
+
+	// Fall back to fuzzy matching to tolerate typos in the sheet
+	if algorithm := fuzzyAlgorithm(opts); algorithm != nil {
+		var bestNames []string
+		bestScore := -1.0
+		for _, guildName := range guildNames {
+			score, ok := algorithm.Compare(matchKey(guildName, opts.CaseSensitive), sheetNameLower)
+			if !ok {
+				continue
+			}
+			if bestScore < 0 || score < bestScore {
+				bestNames = []string{guildName}
+				bestScore = score
+			} else if score == bestScore {
+				bestNames = append(bestNames, guildName)
+			}
+		}
+		if len(bestNames) == 1 {
+			return fuzzyMatchResult(bestNames[0], sheetName, bestNames[0], algorithm, bestScore)
+		}
+		if len(bestNames) > 1 {
+			return ambiguousMatchResult(sheetName, bestNames)
+		}
+	}
+
+	return MatchResult{Found: false}
+}
+
+// logMatchAttempt logs to DebugLog which strategies a match attempt tried, in order up to the
+// one that matched, and the outcome, so a wrong or missing alternative name is easy to spot
+func logMatchAttempt(side string, name string, result MatchResult, opts MatchOptions) {
+	if DebugLog.Writer() == io.Discard {
+		return
+	}
+
+	strategies := []string{"direct", "alternative", "ignored"}
+	if opts.TokenOverlap > 0 {
+		strategies = append(strategies, "token")
+	}
+	if algorithm := fuzzyAlgorithm(opts); algorithm != nil {
+		if _, phonetic := algorithm.(MetaphoneFuzzy); phonetic {
+			strategies = append(strategies, "phonetic")
+		} else {
+			strategies = append(strategies, "fuzzy")
+		}
+	}
+	if result.Found {
+		for i, strategy := range strategies {
+			if strategy == result.MatchType {
+				strategies = strategies[:i+1]
+				break
+			}
+		}
+	}
+
+	outcome := "no match"
+	if result.Found {
+		outcome = fmt.Sprintf("%s match with '%s' (%s)", result.MatchType, result.MatchedName, result.Method)
+	} else if len(result.Candidates) > 0 {
+		outcome = "ambiguous between " + strings.Join(result.Candidates, ", ")
+	}
+	DebugLog.Printf("Matching %s name '%s': tried %s; %s", side, name, strings.Join(strategies, ", "), outcome)
+}
+
+// ambiguousMatchResult warns that a sheet name could be several guild members and leaves it
+// unmatched, so officers disambiguate with an alternative name rather than trusting a guess
+func ambiguousMatchResult(sheetName string, candidates []string) MatchResult {
+	log.Printf("Warning: sheet name '%s' could be any of %s; add an alternative name to pick one",
+		sheetName, strings.Join(candidates, ", "))
+	return MatchResult{Found: false, Candidates: candidates}
+}
+
+// whitespaceMatchResult builds the MatchResult for names that are equal once whitespace and
+// underscores are removed; it still counts as a direct match
+func whitespaceMatchResult(guildName, matchedName string, opts MatchOptions) MatchResult {
+	result := directMatchResult(guildName, matchedName, opts)
+	result.Method += " ignoring whitespace and underscores"
+	result.WhitespaceNormalized = true
+	return result
+}
+
+// directMatchResult builds the MatchResult for names that are equal, ignoring case unless
+// matching is case-sensitive
+func directMatchResult(guildName, matchedName string, opts MatchOptions) MatchResult {
+	method := "case-insensitive name equality"
+	if opts.CaseSensitive {
+		method = "name equality"
+	}
+	return MatchResult{
+		Found:       true,
+		GuildName:   guildName,
+		MatchType:   "direct",
+		MatchedName: matchedName,
+		Confidence:  1,
+		Method:      method,
+	}
+}
+
+// alternativeMatchResult builds the MatchResult for a match through an alternative name,
+// flagging alternative names that are no longer in use
+func alternativeMatchResult(guildName, alt, matchedName string, altNames *AlternativeNames, opts MatchOptions) MatchResult {
+	result := MatchResult{
+		Found:           true,
+		GuildName:       guildName,
+		AlternativeName: alt,
+		MatchType:       "alternative",
+		MatchedName:     matchedName,
+		Confidence:      1,
+		Method:          "alternative name mapping",
+	}
+
+	if altNames.IsFormerName(alt, opts.Now) {
+		result.FormerName = true
+		result.Method = fmt.Sprintf("former name '%s'", alt)
+	}
+
+	return result
+}
+
+// nameTokens splits a name's matchKey into its words, without the punctuation around them
+func nameTokens(name string, caseSensitive bool) []string {
+	var tokens []string
+	for _, field := range strings.Fields(matchKey(name, caseSensitive)) {
+		token := strings.TrimFunc(field, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+		if token != "" {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
+// tokenMatch counts how many of the guild name's words are words of the sheet name, and
+// reports whether that is at least opts.TokenOverlap of them; at least one word always has
+// to be shared, so a tiny overlap doesn't match every name
+func tokenMatch(guildName, sheetName string, opts MatchOptions) (found int, total int, ok bool) {
+	guildTokens := nameTokens(guildName, opts.CaseSensitive)
+	if len(guildTokens) == 0 {
+		return 0, 0, false
+	}
+
+	sheetTokens := make(map[string]bool)
+	for _, token := range nameTokens(sheetName, opts.CaseSensitive) {
+		sheetTokens[token] = true
+	}
+	for _, token := range guildTokens {
+		if sheetTokens[token] {
+			found++
+		}
+	}
+
+	required := max(int(math.Ceil(opts.TokenOverlap*float64(len(guildTokens)))), 1)
+	return found, len(guildTokens), found >= required
+}
+
+// tokenMatchResult builds the MatchResult for a match on shared words, less certain the fewer
+// of the guild name's words were found
+func tokenMatchResult(guildName, sheetName, matchedName string, found, total int) MatchResult {
+	return MatchResult{
+		Found:           true,
+		GuildName:       guildName,
+		AlternativeName: sheetName,
+		MatchType:       "token",
+		MatchedName:     matchedName,
+		Confidence:      0.75 * float64(found) / float64(total),
+		Method:          fmt.Sprintf("%d of %d words of the guild name in the sheet name", found, total),
+	}
+}
+
+// fuzzyMatchResult builds the MatchResult for a fuzzy match between a guild name and a sheet name
+func fuzzyMatchResult(guildName, sheetName, matchedName string, algorithm FuzzyAlgorithm, score float64) MatchResult {
+	result := MatchResult{
+		Found:           true,
+		GuildName:       guildName,
+		AlternativeName: sheetName,
+		MatchType:       "fuzzy",
+		MatchedName:     matchedName,
+	}
+	algorithm.Describe(&result, score)
+	return result
+}
+
+// Levenshtein returns the edit distance between two strings, counted in runes
+func Levenshtein(a, b string) int {
+	ra := []rune(a)
+	rb := []rune(b)
+
+	// Only keep two rows of the distance matrix
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
+
+// FindClosestName returns the candidate with the smallest case-insensitive edit distance to name
+func FindClosestName(name string, candidates []string) (string, int) {
+	closest := ""
+	closestDistance := -1
+	for _, candidate := range candidates {
+		distance := Levenshtein(foldName(name), foldName(candidate))
+		if closestDistance < 0 || distance < closestDistance {
+			closest = candidate
+			closestDistance = distance
+		}
+	}
+	return closest, closestDistance
+}
+
+// DescribeMatch returns a short description of how a match was made, e.g. "via alternative 'PX'"
+func DescribeMatch(match MatchResult) string {
+	switch match.MatchType {
+	case "direct":
+		if match.WhitespaceNormalized {
+			return fmt.Sprintf("directly as '%s' (ignoring whitespace)", match.MatchedName)
+		}
+		return fmt.Sprintf("directly as '%s'", match.MatchedName)
+	case "alternative":
+		if match.FormerName {
+			return fmt.Sprintf("via former name '%s'", match.AlternativeName)
+		}
+		return fmt.Sprintf("via alternative '%s'", match.AlternativeName)
+	case "ignored":
+		return fmt.Sprintf("via pattern match with '%s'", match.MatchedName)
+	case "token":
+		return fmt.Sprintf("via shared words with '%s'", match.AlternativeName)
+	case "fuzzy":
+		if match.Similarity > 0 {
+			return fmt.Sprintf("via fuzzy match with '%s' (similarity %.2f)", match.MatchedName, match.Similarity)
+		}
+		return fmt.Sprintf("via fuzzy match with '%s' (distance %d)", match.MatchedName, match.Distance)
+	case "phonetic":
+		return fmt.Sprintf("via sound-alike '%s'", match.MatchedName)
+	}
+	return fmt.Sprintf("as '%s'", match.MatchedName)
+}
+
+// HasExcludedRole checks if a player has any of the excluded roles
+func HasExcludedRole(playerRoles string, excludedRoles []string) bool {
+	return HasAnyRole(playerRoles, excludedRoles)
+}
+
+// HasAnyRole reports whether the semicolon-separated roles include any of the wanted roles,
+// ignoring case
+func HasAnyRole(roles string, wanted []string) bool {
+	if roles == "" {
+		return false
+	}
+
+	// Create a map of the roles for quick lookup (case-insensitive)
+	roleMap := make(map[string]bool)
+	for _, role := range strings.Split(roles, ";") {
+		cleanRole := strings.TrimSpace(role)
+		if cleanRole != "" {
+			roleMap[strings.ToLower(cleanRole)] = true
+		}
+	}
+
+	for _, wantedRole := range wanted {
+		if roleMap[strings.ToLower(wantedRole)] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Matcher looks for a name among candidate names. The analysis calls it with each online
+// guild name against the sheet names, and with each sheet name against the guild names.
+type Matcher interface {
+	Match(name string, candidates []string) MatchResult
+}
+
+// IndexedMatcher is a Matcher that can reuse a NameIndex of the candidates, which the
+// analysis builds once instead of having every lookup scan the candidates
+type IndexedMatcher interface {
+	Matcher
+	MatchIndexed(name string, candidates []string, index NameIndex) MatchResult
+}
+
+// matchWithIndex uses the index if the matcher supports one
+func matchWithIndex(matcher Matcher, name string, candidates []string, index NameIndex) MatchResult {
+	if indexed, ok := matcher.(IndexedMatcher); ok {
+		return indexed.MatchIndexed(name, candidates, index)
+	}
+	return matcher.Match(name, candidates)
+}
+
+// matchAll matches each name against the candidates, spread over the given number of goroutines;
+// the results are in the order of names, whichever finishes first. Names not yet handed out
+// when ctx is cancelled are left unmatched and the context's error is returned.
+func matchAll(ctx context.Context, matcher Matcher, names []string, candidates []string, index NameIndex, workers int) ([]MatchResult, error) {
+	results := make([]MatchResult, len(names))
+	if workers <= 1 || len(names) < 2 {
+		for i, name := range names {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			results[i] = matchWithIndex(matcher, name, candidates, index)
+		}
+		return results, nil
+	}
+
+	type indexedResult struct {
+		i      int
+		result MatchResult
+	}
+	jobs := make(chan int)
+	done := make(chan indexedResult)
+	var wg sync.WaitGroup
+	for w := 0; w < min(workers, len(names)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				done <- indexedResult{i, matchWithIndex(matcher, names[i], candidates, index)}
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for i := range names {
+			// select picks at random when both are ready, so check first to stop right away
+			if ctx.Err() != nil {
+				return
+			}
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	for finished := range done {
+		results[finished.i] = finished.result
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// GuildNameMatcher is the default Matcher for guild names: direct, alternative, pattern and fuzzy matching
+type GuildNameMatcher struct {
+	AltNames     *AlternativeNames
+	IgnoredNames []string
+	Opts         MatchOptions
+}
+
+// Match finds a guild name among sheet names
+func (m GuildNameMatcher) Match(name string, candidates []string) MatchResult {
+	return FindNameMatch(name, candidates, m.AltNames, m.IgnoredNames, m.Opts)
+}
+
+// MatchIndexed finds a guild name among sheet names, using an index of the sheet names
+func (m GuildNameMatcher) MatchIndexed(name string, candidates []string, index NameIndex) MatchResult {
+	return findNameMatchIndexed(name, candidates, index, m.AltNames, m.IgnoredNames, m.Opts)
+}
+
+// SheetNameMatcher is the default Matcher for sheet names, resolving alternative names back to guild names
+type SheetNameMatcher struct {
+	AltNames     *AlternativeNames
+	IgnoredNames []string
+	Opts         MatchOptions
+}
+
+// Match finds a sheet name among guild names
+func (m SheetNameMatcher) Match(name string, candidates []string) MatchResult {
+	return FindSheetNameMatch(name, candidates, m.AltNames, m.IgnoredNames, m.Opts)
+}
+
+// MatchIndexed finds a sheet name among guild names, using an index of the guild names
+func (m SheetNameMatcher) MatchIndexed(name string, candidates []string, index NameIndex) MatchResult {
+	return findSheetNameMatchIndexed(name, candidates, index, m.AltNames, m.IgnoredNames, m.Opts)
+}
+
+// AnalysisResult holds the outcome of comparing the guild roster with the signup sheet
+type AnalysisResult struct {
+	MissingPlayers         []Player
+	ExcludedPlayers        []ExcludedPlayer
+	GuildMatches           []MatchResult
+	SheetPlayersNotInGuild []string
+	SheetMatches           []MatchResult
+}
+
+// Analyze compares the guild roster with the sheet in both directions. Custom matchers can
+// replace the default matching while reusing the parsing and reporting around it.
+func Analyze(guildPlayers []Player, sheetNames []string, guildMatcher Matcher, sheetMatcher Matcher, opts MatchOptions) AnalysisResult {
+	// The background context is never cancelled, so there is no error
+	result, _ := AnalyzeContext(context.Background(), guildPlayers, sheetNames, guildMatcher, sheetMatcher, opts)
+	return result
+}
+
+// AnalyzeContext is Analyze that stops matching once ctx is cancelled, returning the
+// context's error, so an interrupted run over a huge guild doesn't finish the scan first
+func AnalyzeContext(ctx context.Context, guildPlayers []Player, sheetNames []string, guildMatcher Matcher, sheetMatcher Matcher, opts MatchOptions) (AnalysisResult, error) {
+	var result AnalysisResult
+	var err error
+	result.MissingPlayers, result.ExcludedPlayers, result.GuildMatches, err = findOnlinePlayersNotInSheet(ctx, guildPlayers, sheetNames, guildMatcher, opts)
+	if err != nil {
+		return AnalysisResult{}, err
+	}
+	result.SheetPlayersNotInGuild, result.SheetMatches, err = findSheetPlayersNotInGuild(ctx, guildPlayers, sheetNames, sheetMatcher, opts)
+	if err != nil {
+		return AnalysisResult{}, err
+	}
+	return result, nil
+}
+
+// findOnlinePlayersNotInSheet finds players who are online but not in the sheet and don't have excluded roles
+func findOnlinePlayersNotInSheet(ctx context.Context, guildPlayers []Player, sheetNames []string, matcher Matcher, opts MatchOptions) ([]Player, []ExcludedPlayer, []MatchResult, error) {
+	var result []Player
+	var excluded []ExcludedPlayer
+	var matches []MatchResult
+	sheetIndex := newNameIndex(sheetNames, opts.CaseSensitive)
+
+	// Only online players need to be in the sheet, and only those with a role being checked
+	var onlinePlayers []Player
+	var onlineNames []string
+	for _, player := range guildPlayers {
+		if !player.IsOnline(opts.OnlineStatuses) {
+			continue
+		}
+		if len(opts.OnlyRoles) > 0 && !HasAnyRole(player.Roles, opts.OnlyRoles) {
+			DebugLog.Printf("Skipping guild member %s, who has none of the roles being checked", player.Username)
+			continue
+		}
+		onlinePlayers = append(onlinePlayers, player)
+		onlineNames = append(onlineNames, player.Username)
+	}
+
+	// Check if each player is NOT in sheet (using improved name matching)
+	matchResults, err := matchAll(ctx, matcher, onlineNames, sheetNames, sheetIndex, opts.Workers)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for i, matchResult := range matchResults {
+		player := onlinePlayers[i]
+		if !matchResult.Found {
+			// Check if player has excluded roles
+			if HasExcludedRole(player.Roles, opts.ExcludedRoles) {
+				excluded = append(excluded, ExcludedPlayer{Username: player.Username, Roles: player.Roles, Reason: "special role"})
+			} else if opts.ExcludeNoRole && strings.TrimSpace(player.Roles) == "" {
+				excluded = append(excluded, ExcludedPlayer{Username: player.Username, Roles: player.Roles, Reason: "no role (recruit)"})
+			} else {
+				result = append(result, player)
+			}
+		} else {
+			// Player was found in sheet, record the match
+			matchResult.Roles = player.Roles
+			matchResult.StrippedTag = opts.SheetTags[matchResult.MatchedName]
+			matchResult.StrippedSuffix = opts.SheetSuffixes[matchResult.MatchedName]
+			matchResult.Loadout = opts.SheetLoadouts[matchResult.MatchedName]
+			matches = append(matches, matchResult)
+		}
+	}
+
+	return result, excluded, matches, nil
+}
+
+// findSheetPlayersNotInGuild finds players who are in the sheet but not in the guild
+func findSheetPlayersNotInGuild(ctx context.Context, guildPlayers []Player, sheetNames []string, matcher Matcher, opts MatchOptions) ([]string, []MatchResult, error) {
+	var result []string
+	var matches []MatchResult
+
+	// Create list of all guild player names
+	var guildNames []string
+	rolesByName := make(map[string]string)
+	for _, player := range guildPlayers {
+		guildNames = append(guildNames, player.Username)
+		rolesByName[player.Username] = player.Roles
+	}
+
+	guildIndex := newNameIndex(guildNames, opts.CaseSensitive)
+
+	matchResults, err := matchAll(ctx, matcher, sheetNames, guildNames, guildIndex, opts.Workers)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, matchResult := range matchResults {
+		// Check if sheet player is NOT in guild (using improved name matching)
+		sheetName := sheetNames[i]
+		if !matchResult.Found {
+			result = append(result, sheetName)
+		} else {
+			// Sheet player was found in guild, record the match
+			matchResult.Roles = rolesByName[matchResult.GuildName]
+			matchResult.StrippedTag = opts.SheetTags[sheetName]
+			matchResult.StrippedSuffix = opts.SheetSuffixes[sheetName]
+			matchResult.Loadout = opts.SheetLoadouts[sheetName]
+			matches = append(matches, matchResult)
+		}
+	}
+
+	return result, matches, nil
+}
@@ -0,0 +1,244 @@
+package signup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// linearFind is the direct and alternative lookup the name index replaced, scanning the
+// sheet once per name
+func linearFind(guildName string, sheetNames []string, altNames *AlternativeNames) bool {
+	for _, sheetName := range sheetNames {
+		if foldName(sheetName) == foldName(guildName) {
+			return true
+		}
+	}
+	for _, alt := range altNames.GuildToAlternatives[guildName] {
+		for _, sheetName := range sheetNames {
+			if foldName(sheetName) == foldName(alt) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func BenchmarkNameLookup(b *testing.B) {
+	// The linear scan takes seconds per run on the 5000 player input
+	input := generate(b, 1000, 800)
+	names := Usernames(input.players)
+
+	b.Run("indexed", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			index := newNameIndex(input.sheet, false)
+			for _, name := range names {
+				findNameMatchIndexed(name, input.sheet, index, input.altNames, nil, MatchOptions{})
+			}
+		}
+	})
+	b.Run("linear", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, name := range names {
+				linearFind(name, input.sheet, input.altNames)
+			}
+		}
+	})
+}
+
+func TestFindNameMatchIgnored(t *testing.T) {
+	ignored := []string{"sarge"}
+	tests := []struct {
+		guildName string
+		sheet     []string
+		wantFound bool
+		wantType  string
+		wantName  string
+	}{
+		{"SargeBone", []string{"Sarge"}, true, "ignored", "Sarge"},
+		{"TheSarge", []string{"sargeX"}, true, "ignored", "sargeX"},
+		// A direct match is still preferred over the shared pattern
+		{"Sarge", []string{"SargeBone", "sarge"}, true, "direct", "sarge"},
+		{"SargeBone", []string{"Xandor"}, false, "", ""},
+		{"Xandor", []string{"Sarge"}, false, "", ""},
+	}
+	for _, tt := range tests {
+		got := FindNameMatch(tt.guildName, tt.sheet, NewAlternativeNames(), ignored, MatchOptions{})
+		if got.Found != tt.wantFound || got.MatchType != tt.wantType || got.MatchedName != tt.wantName {
+			t.Errorf("FindNameMatch(%q, %q) = found %v, type %q, name %q; want %v, %q, %q",
+				tt.guildName, tt.sheet, got.Found, got.MatchType, got.MatchedName, tt.wantFound, tt.wantType, tt.wantName)
+		}
+	}
+}
+
+func TestNormalizeWhitespace(t *testing.T) {
+	tests := []struct {
+		guildName string
+		sheetName string
+		normalize bool
+		want      bool
+	}{
+		{"Xandor", "Xan dor", true, true},
+		{"Xandor", "Xan_dor", true, true},
+		{"Xandor", " xan  DOR ", true, true},
+		{"Xan_dor", "Xandor", true, true},
+		{"Xandor", "Xan dor", false, false},
+		{"Xandor", "Xan-dor", true, false},
+	}
+	for _, tt := range tests {
+		opts := MatchOptions{NormalizeWhitespace: tt.normalize}
+		guildSide := FindNameMatch(tt.guildName, []string{tt.sheetName}, NewAlternativeNames(), nil, opts)
+		sheetSide := FindSheetNameMatch(tt.sheetName, []string{tt.guildName}, NewAlternativeNames(), nil, opts)
+		for _, got := range []MatchResult{guildSide, sheetSide} {
+			if got.Found != tt.want {
+				t.Errorf("matching %q with %q (normalize %v): found %v, want %v", tt.guildName, tt.sheetName, tt.normalize, got.Found, tt.want)
+			}
+			if got.Found && !got.WhitespaceNormalized {
+				t.Errorf("matching %q with %q: WhitespaceNormalized is false, want true", tt.guildName, tt.sheetName)
+			}
+		}
+	}
+}
+
+func BenchmarkAnalyzeWorkers(b *testing.B) {
+	input := generate(b, 5000, 4000)
+	// Fuzzy matching warns about the generated names it can't tell apart
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	for _, workers := range []int{1, max(runtime.NumCPU(), 2)} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			opts := MatchOptions{FuzzyRatio: 0.2, Workers: workers}
+			guildMatcher := GuildNameMatcher{AltNames: input.altNames, Opts: opts}
+			sheetMatcher := SheetNameMatcher{AltNames: input.altNames, Opts: opts}
+			for i := 0; i < b.N; i++ {
+				Analyze(input.players, input.sheet, guildMatcher, sheetMatcher, opts)
+			}
+		})
+	}
+}
+
+func TestAnalyzeWorkersMatchSerial(t *testing.T) {
+	var players []Player
+	var sheet []string
+	for i := 0; i < 200; i++ {
+		name := fmt.Sprintf("Player%d", i)
+		players = append(players, Player{Username: name, Status: "Online"})
+		if i%3 != 0 {
+			sheet = append(sheet, name)
+		}
+	}
+	sheet = append(sheet, "Stranger")
+
+	analyze := func(workers int) AnalysisResult {
+		opts := MatchOptions{Workers: workers}
+		return Analyze(players, sheet, GuildNameMatcher{AltNames: NewAlternativeNames(), Opts: opts},
+			SheetNameMatcher{AltNames: NewAlternativeNames(), Opts: opts}, opts)
+	}
+	if serial, parallel := analyze(1), analyze(8); !reflect.DeepEqual(serial, parallel) {
+		t.Errorf("Analyze with 8 workers = %+v, want the serial result %+v", parallel, serial)
+	}
+}
+
+func TestAnalyzeContextCancelled(t *testing.T) {
+	players := []Player{{Username: "Xandor", Status: "Online"}, {Username: "Bone", Status: "Online"}}
+	sheet := []string{"Xandor"}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, workers := range []int{1, 4} {
+		opts := MatchOptions{Workers: workers}
+		result, err := AnalyzeContext(ctx, players, sheet, GuildNameMatcher{AltNames: NewAlternativeNames(), Opts: opts},
+			SheetNameMatcher{AltNames: NewAlternativeNames(), Opts: opts}, opts)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("AnalyzeContext with %d workers returned error %v, want %v", workers, err, context.Canceled)
+		}
+		if !reflect.DeepEqual(result, AnalysisResult{}) {
+			t.Errorf("AnalyzeContext with %d workers returned %+v, want an empty result", workers, result)
+		}
+	}
+}
+
+func TestCaseSensitive(t *testing.T) {
+	altNames, err := ParseAlternativeNames(strings.NewReader("Xandor: XAN\n"))
+	if err != nil {
+		t.Fatalf("ParseAlternativeNames returned error: %v", err)
+	}
+	tests := []struct {
+		name          string
+		guildName     string
+		sheet         []string
+		caseSensitive bool
+		wantFound     bool
+		wantMatched   string
+	}{
+		{name: "differing case, insensitive", guildName: "Xandor", sheet: []string{"XANDOR"}, wantFound: true, wantMatched: "XANDOR"},
+		{name: "differing case, sensitive", guildName: "Xandor", sheet: []string{"XANDOR"}, caseSensitive: true},
+		{name: "same case, sensitive", guildName: "Xandor", sheet: []string{"XANDOR", "Xandor"}, caseSensitive: true, wantFound: true, wantMatched: "Xandor"},
+		{name: "alternative name as written", guildName: "Xandor", sheet: []string{"XAN"}, caseSensitive: true, wantFound: true, wantMatched: "XAN"},
+		{name: "alternative name in other case", guildName: "Xandor", sheet: []string{"xan"}, caseSensitive: true},
+		{name: "alternative name in other case, insensitive", guildName: "Xandor", sheet: []string{"xan"}, wantFound: true, wantMatched: "xan"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := MatchOptions{CaseSensitive: tt.caseSensitive}
+			got := FindNameMatch(tt.guildName, tt.sheet, altNames, nil, opts)
+			if got.Found != tt.wantFound || got.MatchedName != tt.wantMatched {
+				t.Errorf("FindNameMatch(%q, %q) = found %v, matched %q; want %v, %q",
+					tt.guildName, tt.sheet, got.Found, got.MatchedName, tt.wantFound, tt.wantMatched)
+			}
+
+			// The sheet side matches the last sheet name back to the guild name the same way
+			sheetName := tt.sheet[len(tt.sheet)-1]
+			back := FindSheetNameMatch(sheetName, []string{tt.guildName}, altNames, nil, opts)
+			if back.Found != tt.wantFound {
+				t.Errorf("FindSheetNameMatch(%q, %q) found %v, want %v", sheetName, tt.guildName, back.Found, tt.wantFound)
+			}
+		})
+	}
+}
+
+// cancellingMatcher cancels the analysis once it has been asked to match a number of names
+type cancellingMatcher struct {
+	cancel context.CancelFunc
+	after  int32
+	calls  *atomic.Int32
+}
+
+func (m cancellingMatcher) Match(name string, candidates []string) MatchResult {
+	if m.calls.Add(1) == m.after {
+		m.cancel()
+	}
+	return MatchResult{}
+}
+
+func TestAnalyzeContextCancelledMidScan(t *testing.T) {
+	var players []Player
+	for i := 0; i < 100; i++ {
+		players = append(players, Player{Username: fmt.Sprintf("Player%d", i), Status: "Online"})
+	}
+
+	for _, workers := range []int{1, 4} {
+		t.Run(fmt.Sprintf("workers=%d", workers), func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			matcher := cancellingMatcher{cancel: cancel, after: 10, calls: new(atomic.Int32)}
+
+			_, err := AnalyzeContext(ctx, players, nil, matcher, matcher, MatchOptions{Workers: workers})
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("AnalyzeContext returned error %v, want %v", err, context.Canceled)
+			}
+			// Names already handed to a worker still finish, the rest are never matched
+			if calls := int(matcher.calls.Load()); calls > 10+workers {
+				t.Errorf("matcher was called %d times after cancelling at 10, want at most %d", calls, 10+workers)
+			}
+		})
+	}
+}
@@ -0,0 +1,951 @@
+package signup
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// utf8BOM is the byte order mark Excel and Notepad put at the start of UTF-8 exports
+const utf8BOM = "\uFEFF"
+
+// newLineScanner returns a line scanner over r that drops a leading UTF-8 byte order mark,
+// which would otherwise stick to the first name or header
+func newLineScanner(r io.Reader) *bufio.Scanner {
+	reader := bufio.NewReader(r)
+	if start, err := reader.Peek(len(utf8BOM)); err == nil && string(start) == utf8BOM {
+		reader.Discard(len(utf8BOM))
+	}
+	return bufio.NewScanner(reader)
+}
+
+// ParseGuildFile reads and parses the guild.txt file
+func ParseGuildFile(fsys fs.FS, filename string, opts GuildOptions) ([]Player, error) {
+	file, err := fsys.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open guild file: %w", err)
+	}
+	defer file.Close()
+
+	return ParseGuild(file, opts)
+}
+
+// ParseGuild reads and parses guild member data in the guild.txt format
+func ParseGuild(r io.Reader, opts GuildOptions) ([]Player, error) {
+	var players []Player
+	var stats GuildParseStats
+	scanner := newLineScanner(r)
+	lineNum := 0
+	var pendingErr error
+	delimiter := opts.Delimiter
+	headerLines := max(opts.HeaderLines, 0)
+
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+
+		// Without an explicit delimiter, tell comma-separated exports from the column names,
+		// which are the last header line, or from the first data line when there is no header
+		if delimiter == 0 {
+			if headerLines > 0 && lineNum == headerLines {
+				delimiter = detectDelimiter(line)
+			} else if headerLines == 0 && line != "" && !isGuildComment(line, 0, opts.LastOnlineLayout) {
+				delimiter = detectDelimiter(line)
+			}
+		}
+
+		// Skip empty lines, the header and comments
+		switch {
+		case line == "":
+			stats.BlankLines++
+			continue
+		case lineNum <= headerLines:
+			continue
+		case isGuildComment(line, delimiter, opts.LastOnlineLayout):
+			stats.CommentLines++
+			continue
+		}
+
+		// A malformed line is only reported once we know it isn't the last one,
+		// since the last line may be a footer
+		if pendingErr != nil {
+			if err := reportMalformedLine(pendingErr, &stats, opts); err != nil {
+				return nil, err
+			}
+			pendingErr = nil
+		}
+
+		player, err := parseGuildLine(line, delimiter, opts.LastOnlineLayout)
+		if err != nil {
+			pendingErr = fmt.Errorf("malformed line %d: %w", lineNum, err)
+			continue
+		}
+		player.Line = lineNum
+
+		// Everything downstream splits roles on semicolons, so convert other separators once here
+		if opts.RoleDelimiter != "" && opts.RoleDelimiter != ";" {
+			player.Roles = normalizeRoleDelimiter(player.Roles, opts.RoleDelimiter)
+		}
+
+		players = append(players, player)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading guild file: %w", err)
+	}
+
+	// A malformed last line is a footer like "Total: 1200 members" when footers are expected
+	if pendingErr != nil {
+		if opts.SkipFooter {
+			stats.FooterLines++
+		} else if err := reportMalformedLine(pendingErr, &stats, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	stats.TotalLines = lineNum
+	stats.Players = len(players)
+	if opts.Stats != nil {
+		*opts.Stats = stats
+	}
+	return players, nil
+}
+
+// isGuildComment reports whether a guild line is a # comment. Quoted usernames never start
+// with #, but an unquoted comma-separated row like "#Xandor,Online,Officer" can, so a # line
+// is only a comment when the # is alone or followed by a space, or the line isn't a valid row.
+func isGuildComment(line string, delimiter rune, lastOnlineLayout string) bool {
+	rest, found := strings.CutPrefix(line, "#")
+	if !found {
+		return false
+	}
+	if rest == "" || unicode.IsSpace([]rune(rest)[0]) {
+		return true
+	}
+	if delimiter == 0 {
+		delimiter = detectDelimiter(line)
+	}
+	_, err := parseGuildLine(line, delimiter, lastOnlineLayout)
+	return err != nil
+}
+
+// reportMalformedLine counts a malformed guild line and warns about it, or returns it as an
+// error with opts.Strict
+func reportMalformedLine(err error, stats *GuildParseStats, opts GuildOptions) error {
+	if opts.Strict {
+		return err
+	}
+	stats.MalformedLines++
+	log.Printf("Warning: skipping %v", err)
+	return nil
+}
+
+// String summarizes the counts, e.g. "1203 lines: 1200 players, 2 blank, 1 malformed"
+func (stats GuildParseStats) String() string {
+	parts := []string{fmt.Sprintf("%d players", stats.Players)}
+	for _, count := range []struct {
+		n    int
+		what string
+	}{
+		{stats.BlankLines, "blank"},
+		{stats.CommentLines, "comment"},
+		{stats.MalformedLines, "malformed"},
+		{stats.FooterLines, "footer"},
+	} {
+		if count.n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", count.n, count.what))
+		}
+	}
+	return fmt.Sprintf("%d lines: %s", stats.TotalLines, strings.Join(parts, ", "))
+}
+
+// normalizeRoleDelimiter rewrites roles separated by delimiter, like "Officer|Caller",
+// into the semicolon-separated form, trimming each role and dropping blanks
+func normalizeRoleDelimiter(roles string, delimiter string) string {
+	var list []string
+	for _, role := range strings.Split(roles, delimiter) {
+		if role = strings.TrimSpace(role); role != "" {
+			list = append(list, role)
+		}
+	}
+	return strings.Join(list, ";")
+}
+
+// detectDelimiter picks comma for a header line with commas but no tabs, and tab otherwise
+func detectDelimiter(header string) rune {
+	if !strings.Contains(header, "\t") && strings.Contains(header, ",") {
+		return ','
+	}
+	return '\t'
+}
+
+// parseGuildLine parses a single line from guild.txt, with an optional fourth last online field
+func parseGuildLine(line string, delimiter rune, lastOnlineLayout string) (Player, error) {
+	if delimiter != '\t' {
+		return parseGuildCSVLine(line, delimiter, lastOnlineLayout)
+	}
+
+	// Split by tabs
+	parts := strings.Split(line, "\t")
+	if len(parts) < 3 {
+		return Player{}, fmt.Errorf("expected 3 tab-separated fields, got %d", len(parts))
+	}
+
+	// Extract quoted fields
+	username, err := extractQuotedField(parts[0])
+	if err != nil {
+		return Player{}, fmt.Errorf("invalid username field: %w", err)
+	}
+
+	status, err := extractQuotedField(parts[1])
+	if err != nil {
+		return Player{}, fmt.Errorf("invalid status field: %w", err)
+	}
+
+	roles, err := extractQuotedField(parts[2])
+	if err != nil {
+		return Player{}, fmt.Errorf("invalid roles field: %w", err)
+	}
+
+	var lastOnline time.Time
+	if len(parts) > 3 {
+		field, err := extractQuotedField(parts[3])
+		if err != nil {
+			return Player{}, fmt.Errorf("invalid last online field: %w", err)
+		}
+		if lastOnline, err = parseLastOnline(field, lastOnlineLayout); err != nil {
+			return Player{}, err
+		}
+	}
+
+	return Player{
+		Username:   username,
+		Status:     status,
+		Roles:      roles,
+		LastOnline: lastOnline,
+	}, nil
+}
+
+// parseLastOnline parses a last online field; an empty field, for a member never seen, stays zero
+func parseLastOnline(field string, layout string) (time.Time, error) {
+	if field == "" {
+		return time.Time{}, nil
+	}
+	if layout == "" {
+		layout = DefaultLastOnlineLayout
+	}
+	lastOnline, err := time.Parse(layout, field)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid last online field: %w", err)
+	}
+	return lastOnline, nil
+}
+
+// parseGuildCSVLine parses a guild line separated by another delimiter, like a comma, where
+// fields may be quoted or bare and quoted fields may contain the delimiter
+func parseGuildCSVLine(line string, delimiter rune, lastOnlineLayout string) (Player, error) {
+	reader := csv.NewReader(strings.NewReader(line))
+	reader.Comma = delimiter
+	reader.TrimLeadingSpace = true
+	parts, err := reader.Read()
+	if err != nil {
+		return Player{}, fmt.Errorf("invalid %q-separated line: %w", delimiter, err)
+	}
+	if len(parts) < 3 {
+		return Player{}, fmt.Errorf("expected 3 %q-separated fields, got %d", delimiter, len(parts))
+	}
+
+	var lastOnline time.Time
+	if len(parts) > 3 {
+		if lastOnline, err = parseLastOnline(strings.TrimSpace(parts[3]), lastOnlineLayout); err != nil {
+			return Player{}, err
+		}
+	}
+
+	return Player{
+		Username:   strings.TrimSpace(parts[0]),
+		Status:     strings.TrimSpace(parts[1]),
+		Roles:      strings.TrimSpace(parts[2]),
+		LastOnline: lastOnline,
+	}, nil
+}
+
+// extractQuotedField extracts content from a quoted field, where a doubled quote ("")
+// stands for a literal quote as in spreadsheet CSV exports
+func extractQuotedField(field string) (string, error) {
+	field = strings.TrimSpace(field)
+	if len(field) < 2 {
+		return "", fmt.Errorf("field too short to contain quotes")
+	}
+
+	if !strings.HasPrefix(field, "\"") || !strings.HasSuffix(field, "\"") {
+		return "", fmt.Errorf("field is not properly quoted")
+	}
+
+	// Remove surrounding quotes; any quote left inside must be part of a doubled pair
+	content := field[1 : len(field)-1]
+	if strings.Contains(strings.ReplaceAll(content, "\"\"", ""), "\"") {
+		return "", fmt.Errorf("field is not properly quoted")
+	}
+	return strings.ReplaceAll(content, "\"\"", "\""), nil
+}
+
+// NewAlternativeNames returns an empty set of alternative name mappings
+func NewAlternativeNames() *AlternativeNames {
+	return &AlternativeNames{
+		GuildToAlternatives:     make(map[string][]string),
+		AlternativeToGuild:      make(map[string]string),
+		AlternativeToGuildExact: make(map[string]string),
+		Periods:                 make(map[string]AliasPeriod),
+	}
+}
+
+// ParseAlternativeNamesFile reads and parses the sheet-names.txt file
+func ParseAlternativeNamesFile(fsys fs.FS, filename string) (*AlternativeNames, error) {
+	file, err := fsys.Open(filename)
+	if err != nil {
+		// File doesn't exist, return empty mappings
+		return NewAlternativeNames(), nil
+	}
+	defer file.Close()
+
+	return ParseAlternativeNames(file)
+}
+
+// ParseAlternativeNames reads and parses alternative name mappings in the sheet-names.txt format
+func ParseAlternativeNames(r io.Reader) (*AlternativeNames, error) {
+	altNames := NewAlternativeNames()
+
+	scanner := newLineScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		// Skip empty lines and comments
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// Parse line: GuildName:AlternativeName1,AlternativeName2
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			log.Printf("Warning: skipping malformed alternative name line: %s", line)
+			continue
+		}
+
+		guildName := strings.TrimSpace(parts[0])
+		alternativesStr := strings.TrimSpace(parts[1])
+
+		if guildName == "" || alternativesStr == "" {
+			continue
+		}
+
+		// Parse alternative names
+		alternatives := strings.Split(alternativesStr, ",")
+		for _, alt := range alternatives {
+			alt = strings.TrimSpace(alt)
+
+			// Former names can carry the dates they were in use: OldName@2025-01-01..2026-09-01
+			if name, dates, dated := strings.Cut(alt, "@"); dated {
+				alt = strings.TrimSpace(name)
+				period, err := parseAliasPeriod(dates)
+				if err != nil {
+					log.Printf("Warning: ignoring dates of alternative name %s: %v", alt, err)
+				} else if alt != "" {
+					altNames.Periods[strings.ToLower(alt)] = period
+				}
+			}
+
+			if alt != "" {
+				// Store both directions of mapping, case-insensitive and as written
+				altNames.GuildToAlternatives[guildName] = append(altNames.GuildToAlternatives[guildName], alt)
+				altNames.AlternativeToGuild[foldName(alt)] = guildName
+				altNames.AlternativeToGuildExact[matchKey(alt, true)] = guildName
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading alternative names file: %w", err)
+	}
+
+	return altNames, nil
+}
+
+// parseAliasPeriod parses a "FROM..UNTIL" date range of YYYY-MM-DD dates, where either side may be empty
+func parseAliasPeriod(dates string) (AliasPeriod, error) {
+	from, until, found := strings.Cut(strings.TrimSpace(dates), "..")
+	if !found {
+		return AliasPeriod{}, fmt.Errorf("expected a FROM..UNTIL date range, got %q", dates)
+	}
+
+	var period AliasPeriod
+	var err error
+	if from = strings.TrimSpace(from); from != "" {
+		if period.From, err = time.Parse("2006-01-02", from); err != nil {
+			return AliasPeriod{}, fmt.Errorf("invalid start date: %w", err)
+		}
+	}
+	if until = strings.TrimSpace(until); until != "" {
+		if period.Until, err = time.Parse("2006-01-02", until); err != nil {
+			return AliasPeriod{}, fmt.Errorf("invalid end date: %w", err)
+		}
+	}
+
+	return period, nil
+}
+
+// IsFormerName reports whether a dated alternative name stopped being in use before now
+func (altNames *AlternativeNames) IsFormerName(alt string, now time.Time) bool {
+	period, dated := altNames.Periods[strings.ToLower(alt)]
+	// The end date is inclusive, so the name is former from the next day on
+	return dated && !period.Until.IsZero() && now.After(period.Until.AddDate(0, 0, 1))
+}
+
+// UseRosterSpelling rewrites guild names in the mappings that only match a member ignoring
+// case to the member's spelling, since matching looks mappings up by the exact guild name
+func (altNames *AlternativeNames) UseRosterSpelling(players []Player) {
+	members := make(map[string]string)
+	for _, player := range players {
+		members[strings.ToLower(player.Username)] = player.Username
+	}
+
+	for guildName, alternatives := range altNames.GuildToAlternatives {
+		member, exists := members[strings.ToLower(guildName)]
+		if !exists || member == guildName {
+			continue
+		}
+		DebugLog.Printf("Using roster spelling '%s' for alternative names of '%s'", member, guildName)
+		delete(altNames.GuildToAlternatives, guildName)
+		altNames.GuildToAlternatives[member] = append(altNames.GuildToAlternatives[member], alternatives...)
+		for alt, target := range altNames.AlternativeToGuild {
+			if target == guildName {
+				altNames.AlternativeToGuild[alt] = member
+			}
+		}
+		for alt, target := range altNames.AlternativeToGuildExact {
+			if target == guildName {
+				altNames.AlternativeToGuildExact[alt] = member
+			}
+		}
+	}
+}
+
+// ParseRolesFile reads a roles file mapping usernames to roles, in the format
+// Username:Role1;Role2, and returns the roles keyed by lowercase username
+func ParseRolesFile(fsys fs.FS, filename string) (map[string]string, error) {
+	file, err := fsys.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open roles file: %w", err)
+	}
+	defer file.Close()
+
+	roles := make(map[string]string)
+	scanner := newLineScanner(file)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		// Skip empty lines and comments
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			log.Printf("Warning: skipping malformed roles line: %s", line)
+			continue
+		}
+
+		username := strings.TrimSpace(parts[0])
+		if username != "" {
+			roles[strings.ToLower(username)] = strings.TrimSpace(parts[1])
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading roles file: %w", err)
+	}
+
+	return roles, nil
+}
+
+// ParseDiscordIDsFile reads a file mapping usernames to Discord user IDs, in the format
+// Username:123456789012345678, and returns the IDs keyed by lowercase username
+func ParseDiscordIDsFile(fsys fs.FS, filename string) (map[string]string, error) {
+	file, err := fsys.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Discord IDs file: %w", err)
+	}
+	defer file.Close()
+
+	ids := make(map[string]string)
+	scanner := newLineScanner(file)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		// Skip empty lines and comments
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		username, id, found := strings.Cut(line, ":")
+		username = strings.TrimSpace(username)
+		id = strings.TrimSpace(id)
+		if !found || username == "" || id == "" || strings.TrimFunc(id, unicode.IsDigit) != "" {
+			log.Printf("Warning: skipping malformed Discord ID line: %s", line)
+			continue
+		}
+		ids[strings.ToLower(username)] = id
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading Discord IDs file: %w", err)
+	}
+
+	return ids, nil
+}
+
+// MergeRoles adds the roles from a roles file to the matching players, keeping roles they already have
+func MergeRoles(players []Player, roles map[string]string) {
+	for i := range players {
+		extra, exists := roles[strings.ToLower(players[i].Username)]
+		if !exists {
+			continue
+		}
+
+		for _, role := range strings.Split(extra, ";") {
+			role = strings.TrimSpace(role)
+			if role == "" || HasAnyRole(players[i].Roles, []string{role}) {
+				continue
+			}
+			if players[i].Roles == "" {
+				players[i].Roles = role
+			} else {
+				players[i].Roles += ";" + role
+			}
+		}
+	}
+}
+
+// ParseSheetFile reads and parses the sheet.txt file
+func ParseSheetFile(fsys fs.FS, filename string, opts CleanOptions) ([]string, error) {
+	file, err := fsys.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sheet file: %w", err)
+	}
+	defer file.Close()
+
+	return ParseSheet(file, opts)
+}
+
+// ParseSheetFiles reads and merges several sheet files, such as one per roster tab; a name
+// already read from an earlier file is dropped so it only counts once, comparing names like
+// matching does, so ignoring case unless caseSensitive is set
+func ParseSheetFiles(fsys fs.FS, filenames []string, opts CleanOptions, caseSensitive bool) ([]string, error) {
+	var merged []string
+	seen := make(map[string]bool)
+
+	for _, filename := range filenames {
+		names, err := ParseSheetFile(fsys, filename, opts)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+
+		fileNames := make(map[string]bool)
+		for _, name := range names {
+			key := matchKey(name, caseSensitive)
+			if seen[key] {
+				DebugLog.Printf("Skipped sheet entry '%s' in %s, already listed in an earlier sheet file", name, filename)
+				continue
+			}
+			fileNames[key] = true
+			merged = append(merged, name)
+		}
+		for key := range fileNames {
+			seen[key] = true
+		}
+	}
+
+	return merged, nil
+}
+
+// DedupeNames returns the names with repeats left out, keeping the first spelling, and the
+// names that were repeated, once each; names are compared like matching compares them, so
+// ignoring case unless caseSensitive is set
+func DedupeNames(names []string, caseSensitive bool) (unique []string, duplicates []string) {
+	seen := make(map[string]int)
+	for _, name := range names {
+		key := matchKey(name, caseSensitive)
+		seen[key]++
+		switch seen[key] {
+		case 1:
+			unique = append(unique, name)
+		case 2:
+			duplicates = append(duplicates, name)
+		}
+	}
+	return unique, duplicates
+}
+
+// ParseSheet reads and parses signup sheet names in the sheet.txt format
+func ParseSheet(r io.Reader, opts CleanOptions) ([]string, error) {
+	var names []string
+	scanner := newLineScanner(r)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		// Skip empty lines
+		if line == "" {
+			continue
+		}
+
+		// Clean the name (remove parentheses content and extra spaces)
+		cleanName := CleanPlayerName(line, opts)
+		if cleanName != "" {
+			names = append(names, cleanName)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading sheet file: %w", err)
+	}
+
+	return names, nil
+}
+
+// ReadSheetLinesFile reads the raw, uncleaned entries of a sheet file
+func ReadSheetLinesFile(fsys fs.FS, filename string) ([]string, error) {
+	file, err := fsys.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sheet file: %w", err)
+	}
+	defer file.Close()
+
+	return ReadSheetLines(file)
+}
+
+// ReadSheetLinesFiles reads the raw, uncleaned entries of several sheet files, one after another
+func ReadSheetLinesFiles(fsys fs.FS, filenames []string) ([]string, error) {
+	var lines []string
+	for _, filename := range filenames {
+		fileLines, err := ReadSheetLinesFile(fsys, filename)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+		lines = append(lines, fileLines...)
+	}
+	return lines, nil
+}
+
+// ReadSheetLines reads the raw, uncleaned entries of sheet data, skipping empty lines
+func ReadSheetLines(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := newLineScanner(r)
+
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading sheet file: %w", err)
+	}
+
+	return lines, nil
+}
+
+// ParseCombinedFile reads a file holding [guild], [sheet] and [aliases] sections
+// and returns the raw content of each section keyed by its name
+func ParseCombinedFile(fsys fs.FS, filename string) (map[string]string, error) {
+	file, err := fsys.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open combined file: %w", err)
+	}
+	defer file.Close()
+
+	sections := make(map[string]string)
+	current := ""
+	scanner := newLineScanner(file)
+	lineNum := 0
+
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+
+		// Section headers switch where the following lines go
+		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+			current = strings.ToLower(strings.TrimSpace(trimmed[1 : len(trimmed)-1]))
+			if current != "guild" && current != "sheet" && current != "aliases" {
+				log.Printf("Warning: ignoring unknown section [%s] on line %d", current, lineNum)
+			}
+			if _, exists := sections[current]; !exists {
+				sections[current] = ""
+			}
+			continue
+		}
+
+		if current == "" {
+			if trimmed != "" {
+				log.Printf("Warning: skipping line %d outside of any section", lineNum)
+			}
+			continue
+		}
+
+		sections[current] += line + "\n"
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading combined file: %w", err)
+	}
+
+	return sections, nil
+}
+
+// ParseIgnorePatternsFile reads the ignore-patterns.txt file of regular expressions
+// matching names that are left out of the analysis entirely
+func ParseIgnorePatternsFile(fsys fs.FS, filename string) ([]*regexp.Regexp, error) {
+	file, err := fsys.Open(filename)
+	if err != nil {
+		// File doesn't exist, nothing is ignored
+		return nil, nil
+	}
+	defer file.Close()
+
+	var patterns []*regexp.Regexp
+	scanner := newLineScanner(file)
+	lineNum := 0
+
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+
+		// Skip empty lines and comments
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		pattern, err := regexp.Compile(line)
+		if err != nil {
+			log.Printf("Warning: skipping invalid ignore pattern on line %d: %v", lineNum, err)
+			continue
+		}
+
+		patterns = append(patterns, pattern)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading ignore patterns file: %w", err)
+	}
+
+	return patterns, nil
+}
+
+// MatchingIgnorePattern returns the first pattern that matches name, or nil if none does
+func MatchingIgnorePattern(name string, patterns []*regexp.Regexp) *regexp.Regexp {
+	for _, pattern := range patterns {
+		if pattern.MatchString(name) {
+			return pattern
+		}
+	}
+	return nil
+}
+
+// DefaultBlocklist returns the blocklist used when there is no blocklist file
+func DefaultBlocklist() []BlockedWord {
+	return []BlockedWord{
+		{Word: "delete"},
+		{Word: "spam"},
+		{Word: "mess"},
+		{Word: "pedo"},
+	}
+}
+
+// ParseBlocklistFile reads the blocklist, one entry per line with # comments. Entries match
+// anywhere in a name, or only as a whole word when prefixed with "=", e.g. "=mess".
+// The defaults are used if the file doesn't exist.
+func ParseBlocklistFile(fsys fs.FS, filename string) ([]BlockedWord, error) {
+	file, err := fsys.Open(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return DefaultBlocklist(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open blocklist file: %w", err)
+	}
+	defer file.Close()
+
+	var blocklist []BlockedWord
+	scanner := newLineScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		blocked := BlockedWord{Word: strings.ToLower(line)}
+		if word, found := strings.CutPrefix(blocked.Word, "="); found {
+			blocked = BlockedWord{Word: strings.TrimSpace(word), WholeWord: true}
+		}
+		if blocked.Word != "" {
+			blocklist = append(blocklist, blocked)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading blocklist file: %w", err)
+	}
+
+	return blocklist, nil
+}
+
+// DefaultExcludedRoles returns the roles excluded from results when there is no excluded roles file
+func DefaultExcludedRoles() []string {
+	return []string{
+		"Bomber",
+		"Guild Master",
+	}
+}
+
+// ParseExcludedRolesFile reads the roles that should be excluded from results, one per line with
+// # comments, falling back to DefaultExcludedRoles if the file doesn't exist
+func ParseExcludedRolesFile(fsys fs.FS, filename string) ([]string, error) {
+	file, err := fsys.Open(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return DefaultExcludedRoles(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open excluded roles file: %w", err)
+	}
+	defer file.Close()
+
+	// An existing but empty file means no roles are excluded
+	roles := []string{}
+	scanner := newLineScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		roles = append(roles, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading excluded roles file: %w", err)
+	}
+
+	return roles, nil
+}
+
+// DefaultRoleWeights returns how urgent a missing member is by role, keyed by lowercase role
+// name: leaders and core raiders weigh 2 or more, recruits 0, and unlisted roles count as 1
+func DefaultRoleWeights() map[string]int {
+	return map[string]int{
+		"guild master": 3,
+		"right hand":   3,
+		"officer":      3,
+		"core":         2,
+		"veteran":      2,
+		"recruit":      0,
+	}
+}
+
+// ParseRoleWeightsFile reads "Role: weight" lines with # comments, falling back to
+// DefaultRoleWeights if the file doesn't exist; the file replaces the defaults entirely
+func ParseRoleWeightsFile(fsys fs.FS, filename string) (map[string]int, error) {
+	file, err := fsys.Open(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return DefaultRoleWeights(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open role weights file: %w", err)
+	}
+	defer file.Close()
+
+	weights := make(map[string]int)
+	scanner := newLineScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		role, value, found := strings.Cut(line, ":")
+		role = strings.TrimSpace(role)
+		weight, err := strconv.Atoi(strings.TrimSpace(value))
+		if !found || role == "" || err != nil {
+			return nil, fmt.Errorf("line %d: expected \"Role: weight\" with a whole number weight, got %q", lineNum, line)
+		}
+		weights[strings.ToLower(role)] = weight
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading role weights file: %w", err)
+	}
+
+	return weights, nil
+}
+
+// DefaultPlaceholders returns the sheet entries that only hold a slot and aren't player names
+func DefaultPlaceholders() []string {
+	return []string{
+		"TBD",
+		"TBA",
+		"---",
+		"-",
+		"?",
+		"reserved",
+		"x",
+		"n/a",
+	}
+}
+
+// IgnoredNames returns a list of names/partial names that should be ignored in matching
+func IgnoredNames() []string {
+	return []string{
+		"sarge",
+	}
+}
+
+// ParseIgnoredNamesFile reads the partial names that make a guild and sheet name match when
+// both contain one, one per line with # comments; a missing file gives IgnoredNames
+func ParseIgnoredNamesFile(fsys fs.FS, filename string) ([]string, error) {
+	file, err := fsys.Open(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return IgnoredNames(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ignored names file: %w", err)
+	}
+	defer file.Close()
+
+	// An existing but empty file turns the shared-name matching off
+	names := []string{}
+	scanner := newLineScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		names = append(names, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading ignored names file: %w", err)
+	}
+
+	return names, nil
+}
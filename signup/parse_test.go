@@ -0,0 +1,390 @@
+package signup
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestExtractQuotedField(t *testing.T) {
+	tests := []struct {
+		name    string
+		field   string
+		want    string
+		wantErr bool
+	}{
+		{name: "plain", field: `"Xandor"`, want: "Xandor"},
+		{name: "surrounding spaces", field: ` "Xandor" `, want: "Xandor"},
+		{name: "empty", field: `""`, want: ""},
+		{name: "embedded quote", field: `"The ""Brave"" One"`, want: `The "Brave" One`},
+		{name: "only a doubled quote", field: `""""`, want: `"`},
+		{name: "unquoted", field: `Xandor`, wantErr: true},
+		{name: "missing closing quote", field: `"Xandor`, wantErr: true},
+		{name: "unbalanced inner quote", field: `"The "Brave One"`, wantErr: true},
+		{name: "single quote character", field: `"`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := extractQuotedField(tt.field)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("extractQuotedField(%q) = %q, want an error", tt.field, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("extractQuotedField(%q) returned error: %v", tt.field, err)
+			}
+			if got != tt.want {
+				t.Errorf("extractQuotedField(%q) = %q, want %q", tt.field, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectDelimiter(t *testing.T) {
+	tests := []struct {
+		header string
+		want   rune
+	}{
+		{"\"Name\"\t\"Status\"\t\"Roles\"", '\t'},
+		{"Name,Status,Roles", ','},
+		{"\"Name\",\"Status\",\"Roles\"", ','},
+		// A tab wins, since tab-separated roles may contain commas
+		{"\"Name\"\t\"Status\"\t\"Officer, Caller\"", '\t'},
+		{"Name", '\t'},
+		{"", '\t'},
+	}
+	for _, tt := range tests {
+		if got := detectDelimiter(tt.header); got != tt.want {
+			t.Errorf("detectDelimiter(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestParseGuildDelimiters(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		opts  GuildOptions
+		want  []Player
+	}{
+		{
+			name:  "tab detected",
+			input: "\"Name\"\t\"Status\"\t\"Roles\"\n\"Xandor\"\t\"Online\"\t\"Officer\"\n",
+			opts:  GuildOptions{HeaderLines: 1},
+			want:  []Player{{Username: "Xandor", Status: "Online", Roles: "Officer", Line: 2}},
+		},
+		{
+			name:  "comma detected",
+			input: "Name,Status,Roles\nXandor,Online,Officer\n",
+			opts:  GuildOptions{HeaderLines: 1},
+			want:  []Player{{Username: "Xandor", Status: "Online", Roles: "Officer", Line: 2}},
+		},
+		{
+			name:  "quoted field containing the delimiter",
+			input: "Name,Status,Roles\n\"Bone, the Brave\",Online,\"Officer,Caller\"\n",
+			opts:  GuildOptions{HeaderLines: 1},
+			want:  []Player{{Username: "Bone, the Brave", Status: "Online", Roles: "Officer,Caller", Line: 2}},
+		},
+		{
+			name:  "explicit delimiter",
+			input: "Name;Status;Roles\nXandor;Online;Officer\n",
+			opts:  GuildOptions{HeaderLines: 1, Delimiter: ';'},
+			want:  []Player{{Username: "Xandor", Status: "Online", Roles: "Officer", Line: 2}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseGuild(strings.NewReader(tt.input), tt.opts)
+			if err != nil {
+				t.Fatalf("ParseGuild returned error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseGuild = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeRoleDelimiter(t *testing.T) {
+	tests := []struct {
+		roles     string
+		delimiter string
+		want      string
+	}{
+		{"Officer|Caller", "|", "Officer;Caller"},
+		{"Officer, Caller", ",", "Officer;Caller"},
+		{" Officer |  | Caller ", "|", "Officer;Caller"},
+		{"Officer", "|", "Officer"},
+		{"", "|", ""},
+	}
+	for _, tt := range tests {
+		if got := normalizeRoleDelimiter(tt.roles, tt.delimiter); got != tt.want {
+			t.Errorf("normalizeRoleDelimiter(%q, %q) = %q, want %q", tt.roles, tt.delimiter, got, tt.want)
+		}
+	}
+}
+
+func TestParseGuildRoleDelimiter(t *testing.T) {
+	input := "\"Name\"\t\"Status\"\t\"Roles\"\n\"Xandor\"\t\"Online\"\t\"Officer|Caller\"\n"
+	players, err := ParseGuild(strings.NewReader(input), GuildOptions{HeaderLines: 1, RoleDelimiter: "|"})
+	if err != nil {
+		t.Fatalf("ParseGuild returned error: %v", err)
+	}
+	if len(players) != 1 {
+		t.Fatalf("ParseGuild returned %d players, want 1", len(players))
+	}
+	if got, want := players[0].RoleList(), []string{"Officer", "Caller"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("RoleList() = %q, want %q", got, want)
+	}
+}
+
+func TestParseIgnoredNamesFile(t *testing.T) {
+	fsys := fstest.MapFS{
+		"ignored-names.txt": {Data: []byte("# shared names\nsarge\n\n  bot  \n")},
+		"empty.txt":         {Data: []byte("# nothing shared\n")},
+	}
+	tests := []struct {
+		filename string
+		want     []string
+	}{
+		{"ignored-names.txt", []string{"sarge", "bot"}},
+		{"empty.txt", []string{}},
+		{"missing.txt", IgnoredNames()},
+	}
+	for _, tt := range tests {
+		got, err := ParseIgnoredNamesFile(fsys, tt.filename)
+		if err != nil {
+			t.Fatalf("ParseIgnoredNamesFile(%q) returned error: %v", tt.filename, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseIgnoredNamesFile(%q) = %q, want %q", tt.filename, got, tt.want)
+		}
+	}
+}
+
+func TestParseGuildLastOnline(t *testing.T) {
+	seen := time.Date(2024, 3, 1, 18, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		line    string
+		layout  string
+		want    time.Time
+		wantErr bool
+	}{
+		{name: "three columns", line: `"Xandor"	"Online"	"Officer"`},
+		{name: "four columns", line: `"Xandor"	"Online"	"Officer"	"2024-03-01 18:30:00"`, want: seen},
+		{name: "empty last online", line: `"Xandor"	"Online"	"Officer"	""`},
+		{name: "custom layout", line: `"Xandor"	"Online"	"Officer"	"01/03/2024 18:30"`, layout: "02/01/2006 15:04", want: seen},
+		{name: "bad date", line: `"Xandor"	"Online"	"Officer"	"yesterday"`, wantErr: true},
+		{name: "two columns", line: `"Xandor"	"Online"`, wantErr: true},
+		{name: "four comma-separated columns", line: `Xandor,Online,Officer,2024-03-01 18:30:00`, want: seen},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			player, err := parseGuildLine(tt.line, detectDelimiter(tt.line), tt.layout)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseGuildLine(%q) = %+v, want an error", tt.line, player)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseGuildLine(%q) returned error: %v", tt.line, err)
+			}
+			if player.Username != "Xandor" || player.Roles != "Officer" {
+				t.Errorf("parseGuildLine(%q) = %+v, want Xandor with role Officer", tt.line, player)
+			}
+			if !player.LastOnline.Equal(tt.want) {
+				t.Errorf("parseGuildLine(%q).LastOnline = %v, want %v", tt.line, player.LastOnline, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseGuildHeaderLines(t *testing.T) {
+	row := "\"Xandor\"\t\"Online\"\t\"Officer\"\n"
+	tests := []struct {
+		name        string
+		input       string
+		headerLines int
+		wantLine    int
+	}{
+		{name: "no header", input: row, headerLines: 0, wantLine: 1},
+		{name: "negative counts as none", input: row, headerLines: -1, wantLine: 1},
+		{name: "column names", input: "\"Name\"\t\"Status\"\t\"Roles\"\n" + row, headerLines: 1, wantLine: 2},
+		{name: "title and column names", input: "Guild export 2024-03-01\n\"Name\"\t\"Status\"\t\"Roles\"\n" + row, headerLines: 2, wantLine: 3},
+		// The title has a comma, but the delimiter comes from the column names on the last header line
+		{name: "title with a comma", input: "Export, March\n\"Name\"\t\"Status\"\t\"Roles\"\n" + row, headerLines: 2, wantLine: 3},
+		{name: "comma export after a blank line", input: "\nXandor,Online,Officer\n", headerLines: 0, wantLine: 2},
+		{name: "comma export with title", input: "Guild export\nName,Status,Roles\nXandor,Online,Officer\n", headerLines: 2, wantLine: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stats GuildParseStats
+			players, err := ParseGuild(strings.NewReader(tt.input), GuildOptions{HeaderLines: tt.headerLines, Strict: true, Stats: &stats})
+			if err != nil {
+				t.Fatalf("ParseGuild returned error: %v", err)
+			}
+			want := []Player{{Username: "Xandor", Status: "Online", Roles: "Officer", Line: tt.wantLine}}
+			if !reflect.DeepEqual(players, want) {
+				t.Errorf("ParseGuild = %+v, want %+v", players, want)
+			}
+			if stats.MalformedLines != 0 {
+				t.Errorf("ParseGuild counted %d malformed lines, want 0", stats.MalformedLines)
+			}
+		})
+	}
+}
+
+func TestParseBOMFiles(t *testing.T) {
+	fsys := os.DirFS("testdata/bom")
+
+	// Without dropping the BOM the first row's username wouldn't be properly quoted
+	players, err := ParseGuildFile(fsys, "guild.txt", GuildOptions{Strict: true})
+	if err != nil {
+		t.Fatalf("ParseGuildFile returned error: %v", err)
+	}
+	if want := []Player{{Username: "Xandor", Status: "Online", Roles: "Officer", Line: 1}}; !reflect.DeepEqual(players, want) {
+		t.Errorf("ParseGuildFile = %+v, want %+v", players, want)
+	}
+
+	sheet, err := ParseSheetFile(fsys, "sheet.txt", CleanOptions{})
+	if err != nil {
+		t.Fatalf("ParseSheetFile returned error: %v", err)
+	}
+	if want := []string{"Xandor", "Bone"}; !reflect.DeepEqual(sheet, want) {
+		t.Errorf("ParseSheetFile = %q, want %q", sheet, want)
+	}
+
+	altNames, err := ParseAlternativeNamesFile(fsys, "sheet-names.txt")
+	if err != nil {
+		t.Fatalf("ParseAlternativeNamesFile returned error: %v", err)
+	}
+	if got := altNames.AlternativeToGuild["xan"]; got != "Xandor" {
+		t.Errorf("alternative name 'Xan' maps to %q, want %q", got, "Xandor")
+	}
+}
+
+func TestDedupeNames(t *testing.T) {
+	tests := []struct {
+		name           string
+		names          []string
+		caseSensitive  bool
+		wantUnique     []string
+		wantDuplicates []string
+	}{
+		{
+			name:       "no repeats",
+			names:      []string{"Xandor", "Bone"},
+			wantUnique: []string{"Xandor", "Bone"},
+		},
+		{
+			name:           "repeat in other case keeps the first spelling",
+			names:          []string{"Xandor", "Bone", "xandor"},
+			wantUnique:     []string{"Xandor", "Bone"},
+			wantDuplicates: []string{"xandor"},
+		},
+		{
+			name:           "listed three times is reported once",
+			names:          []string{"Bone", "Bone", "Bone"},
+			wantUnique:     []string{"Bone"},
+			wantDuplicates: []string{"Bone"},
+		},
+		{
+			name:           "curly apostrophe",
+			names:          []string{"D'Artagnan", "D’Artagnan"},
+			wantUnique:     []string{"D'Artagnan"},
+			wantDuplicates: []string{"D’Artagnan"},
+		},
+		{
+			name:          "case-sensitive keeps accounts differing by case",
+			names:         []string{"Xandor", "XANDOR"},
+			caseSensitive: true,
+			wantUnique:    []string{"Xandor", "XANDOR"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unique, duplicates := DedupeNames(tt.names, tt.caseSensitive)
+			if !reflect.DeepEqual(unique, tt.wantUnique) {
+				t.Errorf("unique = %q, want %q", unique, tt.wantUnique)
+			}
+			if !reflect.DeepEqual(duplicates, tt.wantDuplicates) {
+				t.Errorf("duplicates = %q, want %q", duplicates, tt.wantDuplicates)
+			}
+		})
+	}
+}
+
+func TestParseSheetFilesCaseSensitive(t *testing.T) {
+	fsys := fstest.MapFS{
+		"sheet-tanks.txt": {Data: []byte("XANDOR\nBone\n")},
+		"sheet-dps.txt":   {Data: []byte("Xandor\nbone\nBone\nApple\n")},
+	}
+	files := []string{"sheet-tanks.txt", "sheet-dps.txt"}
+	tests := []struct {
+		caseSensitive bool
+		want          []string
+	}{
+		// A repeat within one file is kept, so DedupeNames can warn about it
+		{false, []string{"XANDOR", "Bone", "Apple"}},
+		{true, []string{"XANDOR", "Bone", "Xandor", "bone", "Apple"}},
+	}
+	for _, tt := range tests {
+		got, err := ParseSheetFiles(fsys, files, CleanOptions{}, tt.caseSensitive)
+		if err != nil {
+			t.Fatalf("ParseSheetFiles returned error: %v", err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseSheetFiles(caseSensitive=%v) = %q, want %q", tt.caseSensitive, got, tt.want)
+		}
+	}
+}
+
+func TestParseGuildComments(t *testing.T) {
+	tests := []struct {
+		name         string
+		input        string
+		wantPlayers  []string
+		wantComments int
+	}{
+		{
+			name:         "tab export",
+			input:        "# left guild, ignore\n#\"Bone\"\t\"Online\"\t\"Member\"\n\"#Xandor\"\t\"Online\"\t\"Officer\"\n",
+			wantPlayers:  []string{"#Xandor"},
+			wantComments: 2,
+		},
+		{
+			name:         "unquoted comma export",
+			input:        "# left guild, ignore\n#Xandor,Online,Officer\n# Bone,Online,Member\n#note\nApple,Online,Member\n",
+			wantPlayers:  []string{"#Xandor", "Apple"},
+			wantComments: 3,
+		},
+		{
+			name:         "quoted comma export",
+			input:        "\"#Xandor\",\"Online\",\"Officer\"\n#\"Bone\",\"Online\",\"Member\"\n",
+			wantPlayers:  []string{"#Xandor"},
+			wantComments: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stats GuildParseStats
+			players, err := ParseGuild(strings.NewReader(tt.input), GuildOptions{Strict: true, Stats: &stats})
+			if err != nil {
+				t.Fatalf("ParseGuild returned error: %v", err)
+			}
+			if got := Usernames(players); !reflect.DeepEqual(got, tt.wantPlayers) {
+				t.Errorf("players = %q, want %q", got, tt.wantPlayers)
+			}
+			if stats.CommentLines != tt.wantComments {
+				t.Errorf("comment lines = %d, want %d", stats.CommentLines, tt.wantComments)
+			}
+		})
+	}
+}
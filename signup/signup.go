@@ -0,0 +1,180 @@
+// Package signup parses guild exports and signup sheets and matches the two, so the
+// checker can be embedded in other programs such as a Discord bot.
+//
+// A minimal check of one guild member against a sheet:
+//
+//	sheet, err := signup.ParseSheet(strings.NewReader("Xandor (Longbow)\n"), signup.CleanOptions{})
+//	if err != nil {
+//		return err
+//	}
+//	altNames := signup.NewAlternativeNames()
+//	match := signup.FindNameMatch("Xandor", sheet, altNames, nil, signup.MatchOptions{})
+//	fmt.Println(match.Found, signup.DescribeMatch(match))
+//
+// Analyze runs the full comparison in both directions with replaceable Matchers.
+package signup
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"strings"
+	"time"
+)
+
+// Player represents a guild member
+type Player struct {
+	Username   string    `json:"username"`
+	Status     string    `json:"status"`
+	Roles      string    `json:"roles"`
+	LastOnline time.Time `json:"lastOnline"` // from the optional fourth column, zero when absent
+	Line       int       `json:"line"`       // line number in the guild file
+}
+
+// MarshalJSON leaves out a zero LastOnline, so exports without the column look as before
+func (p Player) MarshalJSON() ([]byte, error) {
+	type player Player
+	var lastOnline *time.Time
+	if !p.LastOnline.IsZero() {
+		lastOnline = &p.LastOnline
+	}
+	return json.Marshal(struct {
+		player
+		LastOnline *time.Time `json:"lastOnline,omitempty"`
+	}{player(p), lastOnline})
+}
+
+// RoleList returns the player's roles split on semicolons, without blanks
+func (p Player) RoleList() []string {
+	var roles []string
+	for _, role := range strings.Split(p.Roles, ";") {
+		if role = strings.TrimSpace(role); role != "" {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// IsOnline reports whether the player's status is one of the online statuses, ignoring case;
+// with none given only "Online" counts
+func (p Player) IsOnline(onlineStatuses []string) bool {
+	if len(onlineStatuses) == 0 {
+		onlineStatuses = []string{"Online"}
+	}
+	for _, status := range onlineStatuses {
+		if strings.EqualFold(strings.TrimSpace(p.Status), status) {
+			return true
+		}
+	}
+	return false
+}
+
+// AlternativeNames holds mappings from guild names to alternative names
+type AlternativeNames struct {
+	GuildToAlternatives     map[string][]string    // guild name -> list of alternative names
+	AlternativeToGuild      map[string]string      // alternative name -> guild name
+	AlternativeToGuildExact map[string]string      // alternative name as written -> guild name, for case-sensitive matching
+	Periods                 map[string]AliasPeriod // lowercase alternative name -> when it was in use, for dated entries
+}
+
+// AliasPeriod is the date range during which an alternative name was a member's in-game name
+type AliasPeriod struct {
+	From  time.Time // zero if open-ended
+	Until time.Time // zero if open-ended; after this date the alias is a former name
+}
+
+// ExcludedPlayer is an online player left out of the missing list, with the reason why
+type ExcludedPlayer struct {
+	Username string `json:"username"`
+	Roles    string `json:"roles"`
+	Reason   string `json:"reason"` // "special role" or "no role (recruit)"
+}
+
+// MatchResult represents the result of a name matching operation
+type MatchResult struct {
+	Found                bool     `json:"found"`
+	GuildName            string   `json:"guildName"`
+	AlternativeName      string   `json:"alternativeName,omitempty"`
+	MatchType            string   `json:"matchType"`                      // "direct", "alternative", "ignored", "token", "fuzzy", "phonetic"
+	MatchedName          string   `json:"matchedName"`                    // the exact string on the other side that matched
+	Confidence           float64  `json:"confidence"`                     // 1 for exact and mapped matches, lower for heuristics
+	Method               string   `json:"method"`                         // how the match was produced, for auditing
+	Distance             int      `json:"distance,omitempty"`             // edit distance of fuzzy matches
+	Similarity           float64  `json:"similarity,omitempty"`           // similarity of Jaro-Winkler and trigram fuzzy matches
+	Roles                string   `json:"roles,omitempty"`                // roles of the matched guild member
+	FormerName           bool     `json:"formerName,omitempty"`           // matched through an alternative name no longer in use
+	Candidates           []string `json:"candidates,omitempty"`           // guild members an ambiguous sheet name could be
+	StrippedTag          string   `json:"strippedTag,omitempty"`          // clan tag like "[KOS]" removed from the sheet name with -strip-tags
+	StrippedSuffix       string   `json:"strippedSuffix,omitempty"`       // season or server number like "_S9" removed from the sheet name with -strip-numbers
+	Loadout              string   `json:"loadout,omitempty"`              // parentheses content of the sheet entry, usually the assigned weapon like "Longbow"
+	WhitespaceNormalized bool     `json:"whitespaceNormalized,omitempty"` // matched only after removing whitespace and underscores
+}
+
+// GuildOptions controls how the guild file is parsed
+type GuildOptions struct {
+	SkipFooter       bool             // silently skip a malformed last line, such as a "Total: 1200 members" footer
+	Delimiter        rune             // field separator; 0 detects tab or comma from the header line
+	RoleDelimiter    string           // separator between roles in the export; roles are stored semicolon-separated
+	HeaderLines      int              // leading lines skipped as a header, like a title and the column names; 0 when the first line is data
+	LastOnlineLayout string           // time layout of the optional last online column; empty means DefaultLastOnlineLayout
+	Strict           bool             // fail on a malformed line instead of warning and skipping it; a footer skipped with SkipFooter is still fine
+	Stats            *GuildParseStats // when not nil, filled with the line counts of the parse
+}
+
+// GuildParseStats counts how the lines of a guild file were handled, to validate a fresh export;
+// header lines are the total minus the rest
+type GuildParseStats struct {
+	TotalLines     int `json:"totalLines"`
+	Players        int `json:"players"`
+	BlankLines     int `json:"blankLines"`
+	CommentLines   int `json:"commentLines"`
+	MalformedLines int `json:"malformedLines"` // skipped with a warning
+	FooterLines    int `json:"footerLines"`    // a malformed last line skipped silently with SkipFooter
+}
+
+// DefaultLastOnlineLayout is the time layout of the last online column unless configured
+const DefaultLastOnlineLayout = "2006-01-02 15:04:05"
+
+// CleanOptions controls the optional normalization applied to sheet names
+type CleanOptions struct {
+	StripPrefixChars int               // number of leading runes to remove, e.g. a rank glyph
+	StripSuffixChars int               // number of trailing runes to remove
+	NameInParens     bool              // the name is inside the first parentheses, e.g. "Nickname (RealIGN)"
+	StripNumbers     bool              // remove trailing season/server numbers like "_S9" behind a separator
+	StripSymbols     bool              // remove emoji and other runes that aren't letters, digits or spaces
+	DisabledCleaners map[string]bool   // names of nameCleaners to skip
+	Placeholders     map[string]bool   // lowercase placeholder entries like "tbd" to drop
+	StripTags        bool              // remove a leading clan tag like "[KOS] "
+	StrippedTags     map[string]string // when not nil, filled with the tag removed from each cleaned name
+	StrippedSuffixes map[string]string // when not nil, filled with the number suffix like "_S9" removed from each cleaned name
+	Loadouts         map[string]string // when not nil, filled with the parentheses content removed from each cleaned name, like "Longbow"
+	Blocklist        []BlockedWord     // entries like "spam" whose rows are dropped as junk
+}
+
+// BlockedWord is a blocklist entry; sheet rows containing it are dropped
+type BlockedWord struct {
+	Word      string // lowercase
+	WholeWord bool   // only match the entry as a separate word, so "mess" doesn't drop "Messiah"
+}
+
+// MatchOptions controls the optional matching strategies and which players are flagged
+type MatchOptions struct {
+	FuzzyRatio          float64           // allowed edit distance as a fraction of the guild name length, 0 disables fuzzy matching
+	FuzzyDistance       int               // allowed edit distance regardless of name length, takes precedence over FuzzyRatio; 0 disables
+	FuzzyAlgorithm      FuzzyAlgorithm    // overrides FuzzyRatio and FuzzyDistance when set
+	ExcludeNoRole       bool              // treat online players without any role as recruits and exclude them
+	ExcludedRoles       []string          // online players with any of these roles are excluded instead of listed as missing
+	Now                 time.Time         // reference time for dated alternative names
+	SheetTags           map[string]string // clan tags stripped from sheet names, keyed by the cleaned name
+	SheetSuffixes       map[string]string // number suffixes like "_S9" stripped from sheet names, keyed by the cleaned name
+	SheetLoadouts       map[string]string // parentheses content like "Longbow" removed from sheet names, keyed by the cleaned name
+	OnlineStatuses      []string          // statuses counted as online, like "Online" or "Active"; empty means "Online"
+	NormalizeWhitespace bool              // also match names directly when they only differ in whitespace and underscores
+	Workers             int               // goroutines matching names at once, 0 or 1 for one at a time; Matchers must then be safe for concurrent use
+	TokenOverlap        float64           // fraction of a guild name's words that must be words of a sheet name for a token match, 0 disables
+	CaseSensitive       bool              // compare names and alternative names without lowercasing, for accounts told apart only by case
+	OnlyRoles           []string          // when set, online players without any of these roles aren't checked at all; exclusions still apply to the rest
+}
+
+// DebugLog receives details about how names were handled; it discards them unless given an output
+var DebugLog = log.New(io.Discard, "", log.LstdFlags)
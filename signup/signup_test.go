@@ -0,0 +1,26 @@
+package signup
+
+import "testing"
+
+func TestPlayerIsOnline(t *testing.T) {
+	tests := []struct {
+		status   string
+		statuses []string
+		want     bool
+	}{
+		{"Online", nil, true},
+		{"online", nil, true},
+		{" Online ", nil, true},
+		{"Offline", nil, false},
+		{"Active", nil, false},
+		{"Active", []string{"Online", "Active"}, true},
+		{"In Game", []string{"online", "in game"}, true},
+		{"Online", []string{"Active"}, false},
+	}
+	for _, tt := range tests {
+		player := Player{Username: "Xandor", Status: tt.status}
+		if got := player.IsOnline(tt.statuses); got != tt.want {
+			t.Errorf("Player{Status: %q}.IsOnline(%q) = %v, want %v", tt.status, tt.statuses, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+//go:build ignore
+
+// generate writes synthetic guild.txt, sheet.txt and sheet-names.txt files of a
+// configurable size, for measuring matching performance on large inputs.
+//
+// Usage:
+//
+//	go run testdata/generate.go -players 5000 -sheet 4000 -out /tmp/large/data
+//	cd /tmp/large && time signup-checker -names-only
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var syllables = []string{"xan", "dor", "bo", "ne", "ap", "ple", "tea", "sar", "ge", "hic", "cup", "nor", "ton", "ka", "thr", "yn", "vel", "mir", "zu", "rak"}
+
+var roles = []string{"Member", "Raider", "Officer", "Healer", "Tank", "Bomber", "Guild Master"}
+
+// randomName builds a pronounceable name from a few syllables plus a number to keep it unique
+func randomName(rng *rand.Rand, i int) string {
+	var b strings.Builder
+	for n := 2 + rng.Intn(3); n > 0; n-- {
+		b.WriteString(syllables[rng.Intn(len(syllables))])
+	}
+	name := b.String()
+	return strings.ToUpper(name[:1]) + name[1:] + fmt.Sprint(i)
+}
+
+// typo swaps two adjacent characters, like a hurried sheet entry would
+func typo(rng *rand.Rand, name string) string {
+	runes := []rune(name)
+	if len(runes) < 3 {
+		return name
+	}
+	i := rng.Intn(len(runes) - 1)
+	runes[i], runes[i+1] = runes[i+1], runes[i]
+	return string(runes)
+}
+
+func main() {
+	players := flag.Int("players", 1000, "number of guild members to generate")
+	sheetSize := flag.Int("sheet", 800, "number of sheet entries to generate")
+	online := flag.Float64("online", 0.6, "fraction of guild members that are online")
+	seed := flag.Int64("seed", 1, "random seed, so runs are reproducible")
+	out := flag.String("out", "testdata/large/data", "directory to write the files to")
+	flag.Parse()
+
+	rng := rand.New(rand.NewSource(*seed))
+	if err := os.MkdirAll(*out, 0o755); err != nil {
+		log.Fatalf("Error creating output directory: %v", err)
+	}
+
+	guild, err := os.Create(filepath.Join(*out, "guild.txt"))
+	if err != nil {
+		log.Fatalf("Error creating guild file: %v", err)
+	}
+	defer guild.Close()
+	guildWriter := bufio.NewWriter(guild)
+	defer guildWriter.Flush()
+
+	sheet, err := os.Create(filepath.Join(*out, "sheet.txt"))
+	if err != nil {
+		log.Fatalf("Error creating sheet file: %v", err)
+	}
+	defer sheet.Close()
+	sheetWriter := bufio.NewWriter(sheet)
+	defer sheetWriter.Flush()
+
+	altNames, err := os.Create(filepath.Join(*out, "sheet-names.txt"))
+	if err != nil {
+		log.Fatalf("Error creating alternative names file: %v", err)
+	}
+	defer altNames.Close()
+	altWriter := bufio.NewWriter(altNames)
+	defer altWriter.Flush()
+
+	fmt.Fprintln(guildWriter, "\"Name\"\t\"Status\"\t\"Roles\"")
+	fmt.Fprintln(altWriter, "# Generated alternative names")
+
+	written := 0
+	for i := 0; i < *players; i++ {
+		name := randomName(rng, i)
+		status := "Offline"
+		if rng.Float64() < *online {
+			status = "Online"
+		}
+		role := roles[rng.Intn(len(roles))]
+		fmt.Fprintf(guildWriter, "%q\t%q\t%q\n", name, status, role)
+
+		// Most members sign up, some under an alternative name, some with a typo or annotation
+		if written >= *sheetSize || rng.Float64() > 0.8 {
+			continue
+		}
+		switch r := rng.Float64(); {
+		case r < 0.05:
+			alt := strings.ToLower(name[:3]) + fmt.Sprint(i)
+			fmt.Fprintf(altWriter, "%s:%s\n", name, alt)
+			fmt.Fprintln(sheetWriter, alt)
+		case r < 0.10:
+			fmt.Fprintln(sheetWriter, typo(rng, name))
+		case r < 0.30:
+			fmt.Fprintf(sheetWriter, "%s (Longbow)\n", name)
+		default:
+			fmt.Fprintln(sheetWriter, name)
+		}
+		written++
+	}
+
+	// Fill the rest of the sheet with people who aren't in the guild
+	for ; written < *sheetSize; written++ {
+		fmt.Fprintln(sheetWriter, randomName(rng, *players+written))
+	}
+}